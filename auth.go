@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"image"
+	"image/png"
+	"log"
+	"os"
+	"wallcalendar/canvas"
+	"wallcalendar/waveshare"
+
+	"github.com/furconz/freetype/truetype"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	"google.golang.org/api/calendar/v3"
+	"rsc.io/qr"
+)
+
+// runAuth implements the auth command, which mints token.json with the OAuth
+// 2.0 device authorization grant so the calendar can be set up without a
+// keyboard or browser on the device.
+func runAuth(args []string) {
+	fs := flag.NewFlagSet("auth", flag.ExitOnError)
+	credentials := fs.String("credentials", "credentials.json", "OAuth client of type \"TVs and Limited Input devices\"")
+	tokenPath := fs.String("token", "token.json", "Where to save the token")
+	onScreen := fs.Bool("on_screen", false, "Also show the code and a QR code on the e-paper display")
+	onlyRenderImage := fs.Bool("only_render_image", false, "With --on_screen, write the code to processed.png instead of the display")
+	fs.Parse(args)
+
+	b, err := os.ReadFile(*credentials)
+	if err != nil {
+		log.Fatalf("Unable to read client secret file: %v", err)
+	}
+	config, err := deviceConfigFromJSON(b, calendar.CalendarReadonlyScope)
+	if err != nil {
+		log.Fatalf("Unable to parse client secret file to config: %v", err)
+	}
+
+	tok, err := deviceAuthorize(context.Background(), config, func(da *oauth2.DeviceAuthResponse) {
+		fmt.Printf("Go to %s and enter the code %s\n", da.VerificationURI, da.UserCode)
+		if *onScreen {
+			showDeviceCode(da, *onlyRenderImage)
+		}
+	})
+	if err != nil {
+		log.Fatalf("Unable to authorize device: %v", err)
+	}
+	saveToken(*tokenPath, tok)
+}
+
+// deviceConfigFromJSON is like google.ConfigFromJSON, but accepts clients
+// without redirect URIs and fills in Google's device authorization endpoint.
+func deviceConfigFromJSON(b []byte, scope ...string) (*oauth2.Config, error) {
+	type cred struct {
+		ClientID     string `json:"client_id"`
+		ClientSecret string `json:"client_secret"`
+		TokenURI     string `json:"token_uri"`
+	}
+	var j struct {
+		Web       *cred `json:"web"`
+		Installed *cred `json:"installed"`
+	}
+	if err := json.Unmarshal(b, &j); err != nil {
+		return nil, err
+	}
+	c := j.Installed
+	if c == nil {
+		c = j.Web
+	}
+	if c == nil {
+		return nil, fmt.Errorf("no credentials found")
+	}
+	endpoint := google.Endpoint
+	if c.TokenURI != "" {
+		endpoint.TokenURL = c.TokenURI
+	}
+	return &oauth2.Config{
+		ClientID:     c.ClientID,
+		ClientSecret: c.ClientSecret,
+		Scopes:       scope,
+		Endpoint:     endpoint,
+	}, nil
+}
+
+// deviceAuthorize requests a user code, passes it to show and polls the token
+// endpoint until the user has approved the request on another device.
+func deviceAuthorize(ctx context.Context, config *oauth2.Config, show func(*oauth2.DeviceAuthResponse)) (*oauth2.Token, error) {
+	da, err := config.DeviceAuth(ctx)
+	if err != nil {
+		return nil, err
+	}
+	show(da)
+	return config.DeviceAccessToken(ctx, da)
+}
+
+// showDeviceCode draws the verification URL, the user code and a QR code of
+// the verification URL on the display.
+func showDeviceCode(da *oauth2.DeviceAuthResponse, onlyRenderImage bool) {
+	img := waveshare.NewHorizontalLSB(image.Rect(0, 0, 1304, 984))
+	canv := canvas.NewCanvas(img)
+	goMono := loadFont(gomono.TTF)
+	codeFace := truetype.NewFace(goMono, &truetype.Options{Size: 70, DPI: 72, Hinting: font.HintingFull})
+	textFace := truetype.NewFace(goMono, &truetype.Options{Size: 24, DPI: 72, Hinting: font.HintingFull})
+
+	url := da.VerificationURI
+	if da.VerificationURIComplete != "" {
+		url = da.VerificationURIComplete
+	}
+
+	canv.DrawString("Go to "+da.VerificationURI+" and enter", 0, 120, canv.Width(), textFace, canvas.Black, canvas.Center)
+	canv.DrawString(da.UserCode, 0, 220, canv.Width(), codeFace, canvas.Red, canvas.Center)
+
+	code, err := qr.Encode(url, qr.M)
+	if err != nil {
+		log.Printf("Unable to encode QR code: %v", err)
+	} else {
+		// Scale the modules up to fill most of the space under the code,
+		// keeping the 4 module quiet zone white.
+		scale := 600 / (code.Size + 8)
+		left := (canv.Width() - code.Size*scale) / 2
+		top := 300
+		for y := 0; y < code.Size; y++ {
+			for x := 0; x < code.Size; x++ {
+				if code.Black(x, y) {
+					module := image.Rect(0, 0, scale, scale).Add(image.Pt(left+x*scale, top+y*scale))
+					canv.FillRect(module, canvas.Black)
+				}
+			}
+		}
+	}
+
+	if onlyRenderImage {
+		f, _ := os.Create("processed.png")
+		png.Encode(f, img)
+		return
+	}
+	waveshare.Initialize()
+	defer waveshare.Close()
+	waveshare.Display(img)
+	waveshare.Sleep()
+}
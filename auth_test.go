@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"golang.org/x/oauth2"
+)
+
+func TestDeviceAuthorize(t *testing.T) {
+	polls := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("/device/code", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Form.Get("client_id") != "client" || r.Form.Get("scope") != "calendar" {
+			t.Errorf("device code request form = %v", r.Form)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"device_code":      "device-123",
+			"user_code":        "ABCD-EFGH",
+			"verification_url": "https://example.com/device",
+			"expires_in":       60,
+			"interval":         1,
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Form.Get("device_code") != "device-123" {
+			t.Errorf("token request form = %v", r.Form)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		polls++
+		if polls == 1 {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "authorization_pending"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"access_token":  "access",
+			"refresh_token": "refresh",
+			"token_type":    "Bearer",
+			"expires_in":    3600,
+		})
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	config, err := deviceConfigFromJSON([]byte(`{"installed":{"client_id":"client","client_secret":"secret","token_uri":"`+srv.URL+`/token"}}`), "calendar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.Endpoint.DeviceAuthURL = srv.URL + "/device/code"
+
+	var shown *oauth2.DeviceAuthResponse
+	tok, err := deviceAuthorize(context.Background(), config, func(da *oauth2.DeviceAuthResponse) {
+		shown = da
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if shown == nil || shown.UserCode != "ABCD-EFGH" || shown.VerificationURI != "https://example.com/device" {
+		t.Errorf("shown = %+v", shown)
+	}
+	if tok.AccessToken != "access" || tok.RefreshToken != "refresh" {
+		t.Errorf("token = %+v", tok)
+	}
+	if polls != 2 {
+		t.Errorf("polled %d times, want 2", polls)
+	}
+}
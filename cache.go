@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+)
+
+// EventCache stores the events of the last successful fetch so they can still
+// be shown when fetching fails. An empty Path disables the cache.
+type EventCache struct {
+	Path string
+}
+
+type cachedEvents struct {
+	SyncedAt time.Time `json:"synced_at"`
+	Events   []*Event  `json:"events"`
+}
+
+// Save replaces the cached events.
+func (c EventCache) Save(syncedAt time.Time, events []*Event) error {
+	if c.Path == "" {
+		return nil
+	}
+	b, err := json.Marshal(cachedEvents{SyncedAt: syncedAt, Events: events})
+	if err != nil {
+		return err
+	}
+	// Write to a temporary file first so a failed write never leaves a
+	// truncated cache behind.
+	tmp := c.Path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, c.Path)
+}
+
+// Load returns the cached events overlapping [start, end) and when they were
+// fetched.
+func (c EventCache) Load(start time.Time, end time.Time, tz *time.Location) ([]*Event, time.Time, error) {
+	b, err := os.ReadFile(c.Path)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	var cached cachedEvents
+	if err := json.Unmarshal(b, &cached); err != nil {
+		return nil, time.Time{}, err
+	}
+	var events []*Event
+	for _, e := range cached.Events {
+		if !e.StartTime.Before(end) || e.EndTime.Before(start) {
+			continue
+		}
+		e.StartTime = e.StartTime.In(tz)
+		e.EndTime = e.EndTime.In(tz)
+		events = append(events, e)
+	}
+	return events, cached.SyncedAt.In(tz), nil
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+	"wallcalendar/ical"
+)
+
+// CalDAVSource reads events from a CalDAV calendar collection, as served by
+// Nextcloud, Radicale or iCloud.
+type CalDAVSource struct {
+	URL      string
+	Username string
+	Password string
+}
+
+const calendarQuery = `<?xml version="1.0" encoding="utf-8"?>
+<C:calendar-query xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav">
+  <D:prop>
+    <D:getetag/>
+    <C:calendar-data/>
+  </D:prop>
+  <C:filter>
+    <C:comp-filter name="VCALENDAR">
+      <C:comp-filter name="VEVENT">
+        <C:time-range start="%s" end="%s"/>
+      </C:comp-filter>
+    </C:comp-filter>
+  </C:filter>
+</C:calendar-query>
+`
+
+type multistatus struct {
+	Responses []struct {
+		Href      string `xml:"DAV: href"`
+		Propstats []struct {
+			Status string `xml:"DAV: status"`
+			Prop   struct {
+				CalendarData string `xml:"urn:ietf:params:xml:ns:caldav calendar-data"`
+			} `xml:"DAV: prop"`
+		} `xml:"DAV: propstat"`
+	} `xml:"DAV: response"`
+}
+
+func (s CalDAVSource) Events(ctx context.Context, start time.Time, end time.Time, tz *time.Location) ([]*Event, error) {
+	body := fmt.Sprintf(calendarQuery, start.UTC().Format("20060102T150405Z"), end.UTC().Format("20060102T150405Z"))
+	req, err := http.NewRequestWithContext(ctx, "REPORT", s.URL, bytes.NewBufferString(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/xml; charset=utf-8")
+	req.Header.Set("Depth", "1")
+	if s.Username != "" {
+		req.SetBasicAuth(s.Username, s.Password)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusMultiStatus {
+		return nil, fmt.Errorf("calendar-query on %s: %s", s.URL, resp.Status)
+	}
+
+	var ms multistatus
+	if err := xml.NewDecoder(resp.Body).Decode(&ms); err != nil {
+		return nil, fmt.Errorf("unable to parse calendar-query response: %w", err)
+	}
+
+	var events []*Event
+	for _, r := range ms.Responses {
+		for _, ps := range r.Propstats {
+			if ps.Prop.CalendarData == "" || !strings.Contains(ps.Status, " 200 ") {
+				continue
+			}
+			cal, err := ical.Parse(strings.NewReader(ps.Prop.CalendarData), tz)
+			if err != nil {
+				return nil, fmt.Errorf("unable to parse %s: %w", r.Href, err)
+			}
+			for _, item := range cal.Expand(start, end) {
+				e := NewEventFromICal(item, tz)
+				events = append(events, &e)
+			}
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].StartTime.Before(events[j].StartTime)
+	})
+	return events, nil
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const caldavResponse = `<?xml version="1.0" encoding="utf-8"?>
+<d:multistatus xmlns:d="DAV:" xmlns:cal="urn:ietf:params:xml:ns:caldav">
+  <d:response>
+    <d:href>/calendars/alex/family/fair.ics</d:href>
+    <d:propstat>
+      <d:prop>
+        <d:getetag>"1"</d:getetag>
+        <cal:calendar-data>BEGIN:VCALENDAR
+VERSION:2.0
+BEGIN:VEVENT
+UID:fair
+SUMMARY:County fair
+DTSTART;VALUE=DATE:20241123
+DTEND;VALUE=DATE:20241125
+END:VEVENT
+END:VCALENDAR
+</cal:calendar-data>
+      </d:prop>
+      <d:status>HTTP/1.1 200 OK</d:status>
+    </d:propstat>
+  </d:response>
+  <d:response>
+    <d:href>/calendars/alex/family/piano.ics</d:href>
+    <d:propstat>
+      <d:prop>
+        <d:getetag>"2"</d:getetag>
+        <cal:calendar-data>BEGIN:VCALENDAR
+VERSION:2.0
+BEGIN:VEVENT
+UID:piano
+SUMMARY:Piano lesson
+DTSTART:20241105T220000Z
+DTEND:20241105T230000Z
+RRULE:FREQ=WEEKLY
+END:VEVENT
+END:VCALENDAR
+</cal:calendar-data>
+      </d:prop>
+      <d:status>HTTP/1.1 200 OK</d:status>
+    </d:propstat>
+  </d:response>
+</d:multistatus>
+`
+
+func TestCalDAVSource(t *testing.T) {
+	ny, _ := time.LoadLocation("America/New_York")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, _ := r.BasicAuth()
+		if r.Method != "REPORT" || r.Header.Get("Depth") != "1" || user != "alex" || pass != "secret" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		if !strings.Contains(string(body), `<C:time-range start="20241117T050000Z" end="20241215T050000Z"/>`) {
+			t.Errorf("request has wrong time-range:\n%s", body)
+		}
+		w.WriteHeader(http.StatusMultiStatus)
+		io.WriteString(w, caldavResponse)
+	}))
+	defer srv.Close()
+
+	today := time.Date(2024, 11, 21, 0, 0, 0, 0, ny)
+	source := CalDAVSource{URL: srv.URL + "/calendars/alex/family/", Username: "alex", Password: "secret"}
+	window, err := FetchEvents(today, FourWeekView, time.Sunday, source, EventCache{}, nil, ny)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fair := window.Dates[time.Date(2024, 11, 24, 0, 0, 0, 0, ny)]
+	if len(fair) != 1 || !fair[0].IsAllDayEvent {
+		t.Fatalf("events on Nov 24 = %+v, want the all-day fair", fair)
+	}
+	if want := time.Date(2024, 11, 24, 23, 59, 59, 0, ny); !fair[0].EndTime.Equal(want) {
+		t.Errorf("fair ends %v, want %v", fair[0].EndTime, want)
+	}
+	if n := len(window.Dates[time.Date(2024, 11, 25, 0, 0, 0, 0, ny)]); n != 0 {
+		t.Errorf("got %d events on Nov 25, want none", n)
+	}
+
+	var lessons []string
+	for d := window.Start; !d.After(window.LastDay); d = d.AddDate(0, 0, 1) {
+		for _, e := range window.Dates[d] {
+			if e.Summary == "Piano lesson" {
+				lessons = append(lessons, e.StartTime.Format("Jan 2 3:04pm"))
+			}
+		}
+	}
+	want := "Nov 19 5:00pm,Nov 26 5:00pm,Dec 3 5:00pm,Dec 10 5:00pm"
+	if strings.Join(lessons, ",") != want {
+		t.Errorf("lessons = %v, want %v", lessons, want)
+	}
+}
+
+func TestCalDAVSourceError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+
+	_, err := CalDAVSource{URL: srv.URL}.Events(context.Background(), time.Now(), time.Now().AddDate(0, 0, 28), time.UTC)
+	if err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("err = %v, want 401", err)
+	}
+}
@@ -2,9 +2,11 @@ package main
 
 import (
 	"fmt"
+	"image"
 	"strings"
 	"time"
 	"wallcalendar/canvas"
+	"wallcalendar/locale"
 
 	"github.com/lovelydeng/gomoji"
 	"golang.org/x/image/font"
@@ -14,8 +16,19 @@ const (
 	margin       = 8
 	cellPadding  = 5
 	headerHeight = 140
+	// weekNumberWidth is the width of the week number column.
+	weekNumberWidth = 40
+	// agendaColumns is the number of columns of AgendaView.
+	agendaColumns = 2
 )
 
+// CalendarStyle is how the events of one calendar are drawn.
+type CalendarStyle struct {
+	Color  canvas.Color
+	Prefix string
+	Marker string
+}
+
 type Calendar struct {
 	canv        canvas.Canvas
 	monthFace   font.Face
@@ -23,6 +36,10 @@ type Calendar struct {
 	eventFace   font.Face
 	batteryFont font.Face
 	tz          *time.Location
+	secondaryTZ *time.Location
+	styles      map[string]CalendarStyle
+	display     DisplayConfig
+	loc         locale.Locale
 }
 
 func NewCalendar(
@@ -31,7 +48,11 @@ func NewCalendar(
 	dateFace font.Face,
 	eventFace font.Face,
 	batteryFont font.Face,
-	tz *time.Location) Calendar {
+	tz *time.Location,
+	secondaryTZ *time.Location,
+	styles map[string]CalendarStyle,
+	display DisplayConfig,
+	loc locale.Locale) Calendar {
 	return Calendar{
 		canv:        canv,
 		monthFace:   monthFace,
@@ -39,7 +60,40 @@ func NewCalendar(
 		eventFace:   eventFace,
 		batteryFont: batteryFont,
 		tz:          tz,
+		secondaryTZ: secondaryTZ,
+		styles:      styles,
+		display:     display,
+		loc:         loc,
+	}
+}
+
+// maxLines returns the most lines the text of e may take, 0 for any number.
+func (c Calendar) maxLines(e *Event) int {
+	switch {
+	case e.MultiDay(c.tz):
+		return c.display.MaxLines.MultiDay
+	case e.IsAllDayEvent:
+		return c.display.MaxLines.AllDay
+	}
+	return c.display.MaxLines.Events
+}
+
+func (c Calendar) style(e *Event) CalendarStyle {
+	if s, ok := c.styles[e.Calendar]; ok {
+		return s
+	}
+	return CalendarStyle{Color: canvas.Black}
+}
+
+// eventLabel returns the marker and prefix of the event's calendar, followed
+// by a space, or "" if the calendar has neither.
+func (c Calendar) eventLabel(e *Event) string {
+	s := c.style(e)
+	label := strings.TrimSpace(s.Marker + " " + s.Prefix)
+	if label == "" {
+		return ""
 	}
+	return label + " "
 }
 
 func (c Calendar) RenderMonth(m string) {
@@ -47,30 +101,120 @@ func (c Calendar) RenderMonth(m string) {
 
 }
 
+// RenderStaleBanner warns that the events shown were fetched at syncedAt and
+// may be out of date, naming the calendars if only theirs are.
+func (c Calendar) RenderStaleBanner(syncedAt time.Time, calendars []string) {
+	t := syncedAt.In(c.tz)
+	text := fmt.Sprintf(c.loc.LastSynced, c.loc.Day(t)+" "+c.loc.Time(t))
+	if len(calendars) > 0 {
+		text = strings.Join(calendars, ", ") + ": " + text
+	}
+	c.canv.DrawString(text, 0, 40, c.canv.Width()-margin, c.dateFace, canvas.Red, canvas.Right)
+}
+
 func (c Calendar) ColumnWidth() int {
-	return (c.canv.Width() - margin*2) / 7
+	return (c.canv.Width() - margin*2 - c.weekNumberWidth()) / 7
+}
+
+// weekNumberWidth returns the width of the week number column, 0 if it is not
+// shown.
+func (c Calendar) weekNumberWidth() int {
+	if c.display.WeekNumbers {
+		return weekNumberWidth
+	}
+	return 0
+}
+
+// columnLeft returns the left edge of the day column col.
+func (c Calendar) columnLeft(col int) int {
+	return margin + c.weekNumberWidth() + c.ColumnWidth()*col
+}
+
+// weekdays returns the days of the week in column order.
+func (c Calendar) weekdays() []time.Weekday {
+	first := c.display.FirstWeekday()
+	days := make([]time.Weekday, 7)
+	for i := range days {
+		days[i] = (first + time.Weekday(i)) % 7
+	}
+	return days
 }
 
 func (c Calendar) RowHeight() int {
 	return (c.canv.Height() - margin*2 - headerHeight) / 4
 }
 
-func (c Calendar) RenderDayHeaders() {
+// RenderDayHeaders draws the weekday names over the columns of the first row
+// of plan.
+func (c Calendar) RenderDayHeaders(plan LayoutPlan) {
 	columnWidth := c.ColumnWidth()
 
-	daysOfWeek := []string{
-		"SUNDAY",
-		"MONDAY",
-		"TUESDAY",
-		"WEDNESDAY",
-		"THURSDAY",
-		"FRIDAY",
-		"SATURDAY",
+	// All headers are abbreviated if any full name is too wide.
+	name := c.loc.Weekday
+	for _, day := range c.weekdays() {
+		if font.MeasureString(c.dateFace, strings.ToUpper(c.loc.Weekday(day))).Ceil() > columnWidth-cellPadding*2 {
+			name = c.loc.ShortWeekday
+		}
+	}
+	for i, day := range c.weekdays() {
+		r := plan.Rows[0].Days[i].Rect
+		label := []rune(strings.ToUpper(name(day)))
+		if r.Dx() < columnWidth {
+			// Compressed columns show as many letters of the short name
+			// as fit.
+			label = []rune(strings.ToUpper(c.loc.ShortWeekday(day)))
+			for len(label) > 1 && font.MeasureString(c.dateFace, string(label)).Ceil() > r.Dx()-cellPadding*2 {
+				label = label[:len(label)-1]
+			}
+		}
+		c.canv.DrawString(string(label), r.Min.X, headerHeight, r.Dx(), c.dateFace, canvas.Black, canvas.Center)
+	}
+}
+
+// RenderWeekNumber draws the ISO week number of the week row starting at
+// start in the row at rowY, if week numbers are shown.
+func (c Calendar) RenderWeekNumber(start time.Time, rowY int) {
+	if !c.display.WeekNumbers {
+		return
+	}
+	y := rowY + c.dateFace.Metrics().Height.Ceil() + cellPadding
+	c.canv.DrawString(fmt.Sprint(isoWeek(start)), margin, y, weekNumberWidth, c.eventFace, canvas.Black, canvas.Center)
+}
+
+// drawBar draws the bar b of a multi-day event starting in column col of row.
+// Bars reach into the margins when the event goes on in the row before or
+// after, and to the cell edge when it goes on from a hidden past day.
+func (c Calendar) drawBar(b EventBox, row RowPlan, col int) {
+	metrics := c.eventFace.Metrics()
+	left := row.Days[col].Rect.Min.X + cellPadding
+	right := row.Days[col+b.Days-1].Rect.Max.X - cellPadding
+	top := b.Y - metrics.Ascent.Ceil() - 2
+	bottom := b.Y + (len(b.Lines)-1)*metrics.Height.Ceil() + metrics.Descent.Ceil() + 2
+	bar := image.Rect(left, top, right, bottom)
+	if b.Continued {
+		bar.Min.X = row.Days[col].Rect.Min.X
+		if col == 0 {
+			bar.Min.X -= margin
+		}
+	}
+	if !b.Ends {
+		bar.Max.X = row.Days[6].Rect.Max.X + margin
+	}
+	color := c.style(b.Event).Color
+	if b.Event.Highlight {
+		color = canvas.Red
 	}
+	c.canv.FillRect(bar, color)
 
-	for i, day := range daysOfWeek {
-		c.canv.DrawString(day, margin+i*columnWidth, headerHeight, columnWidth, c.dateFace, canvas.Black, canvas.Center)
+	align := canvas.Left
+	if c.display.MultiDayBars == "center" {
+		align = canvas.Center
 	}
+	widths := make([]int, len(row.Days))
+	for j, day := range row.Days {
+		widths[j] = day.Rect.Dx()
+	}
+	c.canv.DrawLines(b.Text, b.Lines, left+cellPadding, b.Y, barTextWidth(b, col, widths), c.eventFace, b.Colors, align)
 }
 
 func (c Calendar) drawCarryoverLine(e *Event, x int, y int, columnWidth int, date time.Time, column int, dropLeftMargin bool) {
@@ -91,90 +235,256 @@ func (c Calendar) drawCarryoverLine(e *Event, x int, y int, columnWidth int, dat
 	}
 }
 
-func (c Calendar) Render(col int, row int, date time.Time, events []*Event, isToday bool, slotHeights map[int]int, rowY int, rowHeight int) {
-	columnWidth := c.ColumnWidth()
+// timeLabel returns the time of the event as set by display.time_label,
+// followed by the start time in the secondary time zone if there is one,
+// e.g. "3–5pm (9pm CET) ".
+func (c Calendar) timeLabel(e *Event) string {
+	start, end := e.StartTime.In(c.tz), e.EndTime.In(c.tz)
+	label := c.loc.Time(start)
+	switch c.display.TimeLabel {
+	case "range":
+		if end.After(start) && isSameDay(start, end, c.tz) {
+			label = c.loc.Range(start, end)
+		}
+	case "duration":
+		if end.After(start) {
+			label += " " + locale.Duration(end.Sub(start))
+		}
+	}
+	label += " "
+	if c.secondaryTZ == nil {
+		return label
+	}
+	secondary := e.StartTime.In(c.secondaryTZ)
+	return label + "(" + c.loc.Time(secondary) + " " + secondary.Format("MST") + ") "
+}
+
+// untilLabel returns the last day of an event lasting several days that
+// began before first or ends after last, e.g. "until Mon 12/2 ", or "" if
+// there is none to show.
+func (c Calendar) untilLabel(e *Event, first time.Time, last time.Time) string {
+	if !c.display.ShowUntil || !e.MultiDay(c.tz) {
+		return ""
+	}
+	if !e.StartTime.Before(first) && !midnight(e.EndTime, c.tz).After(last) {
+		return ""
+	}
+	return c.loc.UntilDay(e.EndTime.In(c.tz)) + " "
+}
+
+// locationLine returns the line drawn under the event's title, or "" if
+// there is none.
+func (c Calendar) locationLine(e *Event) string {
+	if !c.display.ShowLocation || e.ShortLocation() == "" {
+		return ""
+	}
+	return "@ " + e.ShortLocation()
+}
+
+// eventText returns the line drawn for an event after timePart, which is
+// drawn in red, with the colors of its parts.
+func (c Calendar) eventText(e *Event, timePart string) (string, []canvas.ColorSpan) {
+	label := c.eventLabel(e)
+	textColor := c.style(e).Color
+	if e.Highlight {
+		textColor = canvas.Red
+	}
+	redSpan := len(timePart)
+	firstWord := strings.Split(e.Summary, " ")[0]
+	var cols []canvas.ColorSpan
+	if redSpan > 0 {
+		cols = append(cols, canvas.ColorSpan{Start: 0, Color: canvas.Red})
+	}
+	if label != "" {
+		cols = append(cols, canvas.ColorSpan{Start: redSpan, Color: textColor})
+	}
+	if gomoji.ContainsEmoji(firstWord) {
+		cols = append(cols, canvas.ColorSpan{Start: redSpan + len(label), Color: canvas.Red})
+		cols = append(cols, canvas.ColorSpan{Start: redSpan + len(label) + len(firstWord), Color: textColor})
+	} else {
+		cols = append(cols, canvas.ColorSpan{Start: redSpan + len(label), Color: textColor})
+	}
+	return timePart + label + e.Summary, cols
+}
+
+// RenderLayout draws the week rows of plan. Bars go over the cells they span
+// and the patterns of faded and past days over everything.
+func (c Calendar) RenderLayout(plan LayoutPlan) {
+	for _, row := range plan.Rows {
+		c.RenderWeekNumber(row.Start, row.Y)
+		for _, day := range row.Days {
+			c.renderDay(day)
+		}
+		for _, day := range row.Days {
+			for _, b := range day.Events {
+				if b.Bar && !b.Carryover {
+					c.drawBar(b, row, day.Col)
+				}
+			}
+		}
+		for _, day := range row.Days {
+			c.renderPast(day)
+			if day.Faded {
+				c.canv.Fade(day.Rect)
+			}
+		}
+	}
+}
 
-	boxLeft := margin + columnWidth*col
-	boxTop := rowY
+// renderPast draws the pattern of display.past_days over day if it is past.
+func (c Calendar) renderPast(day DayPlan) {
+	if !day.Past {
+		return
+	}
+	r := day.Rect.Inset(cellPadding)
+	r.Min.Y = day.Rect.Min.Y + 1
+	switch c.display.PastDays {
+	case "hatch":
+		c.canv.Hatch(r, 12, canvas.Black)
+	case "dots":
+		c.canv.Stipple(r, 6, canvas.Black)
+	}
+}
+
+func (c Calendar) renderDay(day DayPlan) {
+	columnWidth := day.Rect.Dx()
+	boxLeft := day.Rect.Min.X
+	boxTop := day.Rect.Min.Y
+	date := day.Date
+	lineHeight := c.eventFace.Metrics().Height.Ceil()
 
 	c.canv.DrawHorizontalLine(boxLeft+cellPadding, boxTop, columnWidth-cellPadding*2, canvas.Black)
 
-	if isToday {
+	if day.Today {
 		size := font.MeasureString(c.dateFace, date.Format("2"))
 		left := boxLeft + cellPadding + size.Round()/2
 		top := boxTop - cellPadding + c.dateFace.Metrics().Height.Ceil()
-		c.canv.DrawCircle(left, top, 18, canvas.Red)
-		c.canv.DrawCircle(left, top, 15, canvas.White)
-	}
-	c.canv.DrawString(date.Format("2"), boxLeft+cellPadding, boxTop+c.dateFace.Metrics().Height.Ceil()+cellPadding, columnWidth, c.dateFace, canvas.Black, canvas.Left)
-
-	baseY := boxTop + 55
-	// Padding between events
-	eventPadding := int(float64(c.eventFace.Metrics().Height.Round()) * 0.5)
-
-	isFirstCalendarDay := row == 0 && col == 0
-	for _, e := range events {
-		// Calculate y based on slot heights
-		y := baseY
-		for i := 0; i < e.Slot; i++ {
-			if h, ok := slotHeights[i]; ok {
-				y += h + eventPadding
-			} else {
-				// Fallback if slot height not found (shouldn't happen if logic is correct)
-				y += c.eventFace.Metrics().Height.Ceil() + eventPadding
-			}
+		c.canv.DrawRing(left, top, 18, canvas.Stroke{Color: canvas.Red, Width: 3})
+	}
+	dateBaseline := boxTop + c.dateFace.Metrics().Height.Ceil() + cellPadding
+	c.canv.DrawString(date.Format("2"), boxLeft+cellPadding, dateBaseline, columnWidth, c.dateFace, canvas.Black, canvas.Left)
+
+	if day.Holiday != "" {
+		// A single line sits on the date's baseline, two fill the header.
+		y := dateBaseline
+		if len(day.HolidayLines) > 1 {
+			y = boxTop + cellPadding + c.eventFace.Metrics().Ascent.Ceil()
+		}
+		x := boxLeft + cellPadding*2 + font.MeasureString(c.dateFace, date.Format("2")).Ceil()
+		c.canv.DrawLines(day.Holiday, day.HolidayLines, x, y, boxLeft+columnWidth-cellPadding-x, c.eventFace, []canvas.ColorSpan{{Start: 0, Color: canvas.Red}}, canvas.Left)
+	}
+
+	if day.Hidden > 0 {
+		c.canv.DrawString(fmt.Sprintf(c.loc.More, day.Hidden), boxLeft+cellPadding, day.Rect.Max.Y-c.eventFace.Metrics().Descent.Ceil(), columnWidth-cellPadding*2, c.eventFace, canvas.Red, canvas.Left)
+	}
+
+	for _, b := range day.Events {
+		e, y := b.Event, b.Y
+		if b.Bar {
+			continue
 		}
+		if b.Carryover {
+			c.drawCarryoverLine(e, boxLeft, y, columnWidth, date, day.Col, true)
+			continue
+		}
+		if b.Continued {
+			c.canv.DrawHorizontalArrow(boxLeft+cellPadding, y-c.eventFace.Metrics().Ascent.Ceil()/2, int(1.5*float64(font.MeasureString(c.eventFace, " ").Ceil())), canvas.Red, canvas.ArrowLeft)
+		}
+		width := columnWidth - cellPadding*2
+		widths := c.canv.DrawLines(b.Text, b.Lines, boxLeft+cellPadding, y, width, c.eventFace, b.Colors, canvas.Left)
+		if b.Location != "" {
+			c.canv.DrawLines(b.Location, b.LocationLines, boxLeft+cellPadding, y+len(b.Lines)*lineHeight, width, c.eventFace, nil, canvas.Left)
+		}
+		if e.MultiDay(c.tz) {
+			c.drawCarryoverLine(e, boxLeft+cellPadding+widths[0], y, columnWidth-cellPadding-widths[0], date, day.Col, false)
+		}
+	}
+}
 
-		// Check if y is out of bounds for the cell
-		if y+slotHeights[e.Slot] > boxTop+rowHeight {
-			continue // Skip rendering if it overflows the cell
+// RenderAgenda lists the events of window day by day, each day under a
+// heading, flowing into agendaColumns columns below the title.
+func (c Calendar) RenderAgenda(window EventWindow, today time.Time) {
+	columnWidth := (c.canv.Width() - margin*2) / agendaColumns
+	lineHeight := c.eventFace.Metrics().Height.Ceil()
+	eventPadding := lineHeight / 2
+	headingHeight := lineHeight + cellPadding*2
+	dayGap := c.dateFace.Metrics().Height.Ceil() - lineHeight + eventPadding
+
+	// The last column keeps its last line for the "+N more" marker.
+	bottom := func(col int) int {
+		if col == agendaColumns-1 {
+			return c.canv.Height() - margin - lineHeight
 		}
+		return c.canv.Height() - margin
+	}
 
-		startsToday := e.StartsOnDate(date, c.tz)
-		if !startsToday && !isFirstCalendarDay {
-			c.drawCarryoverLine(e, boxLeft, y, columnWidth, date, col, true)
-		} else {
+	top := headerHeight
+	y := top
+	col := 0
+	hidden := 0
+	for date := window.Start; !date.After(window.LastDay); date = date.AddDate(0, 0, 1) {
+		for n, e := range window.Dates[date] {
+			startsToday := e.StartsOnDate(date, c.tz)
 			timePart := ""
-			redSpan := 0
 			if !startsToday {
-				// Add spacing for continuation arrow
-				timePart += "  "
-				c.canv.DrawHorizontalArrow(boxLeft+cellPadding, y-c.eventFace.Metrics().Ascent.Ceil()/2, int(1.5*float64(font.MeasureString(c.eventFace, " ").Ceil())), canvas.Red, canvas.ArrowLeft)
+				timePart = "  "
+			} else if !e.IsAllDayEvent {
+				timePart = c.timeLabel(e) + " "
+			}
+			timePart += c.untilLabel(e, window.Start, window.LastDay)
+			text, cols := c.eventText(e, timePart)
+			width := columnWidth - cellPadding*2
+			h := c.canv.MeasureMultiColorString(text, width, c.maxLines(e), c.eventFace)
+			if location := c.locationLine(e); location != "" {
+				h += c.canv.MeasureMultiColorString(location, width, 0, c.eventFace)
 			}
-			if !e.IsAllDayEvent && startsToday {
-				timePart += e.StartTimeShort(c.tz) + " "
+
+			// Every day starts with a heading, repeated at the top of the
+			// next column if the day continues there.
+			needs := h
+			if n == 0 && y != top {
+				needs += dayGap
 			}
-			redSpan = len(timePart)
-			firstWord := strings.Split(e.Summary, " ")[0]
-			if gomoji.ContainsEmoji(firstWord) {
-				redSpan += len(firstWord)
+			if n == 0 || y == top {
+				needs += headingHeight
 			}
-			var cols []canvas.ColorSpan
-			if redSpan == 0 {
-				cols = []canvas.ColorSpan{
-					{
-						Start: 0,
-						Color: canvas.Black,
-					},
-				}
-			} else {
-				cols = []canvas.ColorSpan{
-					{
-						Start: 0,
-						Color: canvas.Red,
-					},
-					{
-						Start: redSpan,
-						Color: canvas.Black,
-					},
+			if col < agendaColumns && y+needs > bottom(col) {
+				col++
+				y = top
+				needs = h + headingHeight
+			}
+			if col >= agendaColumns {
+				hidden++
+				continue
+			}
+
+			x := margin + col*columnWidth + cellPadding
+			if n == 0 && y != top {
+				y += dayGap
+			}
+			if n == 0 || y == top {
+				color := canvas.Black
+				if date.Equal(today) {
+					color = canvas.Red
 				}
+				c.canv.DrawString(c.loc.Day(date), x, y, width, c.dateFace, color, canvas.Left)
+				c.canv.DrawHorizontalLine(x, y+cellPadding, width, canvas.Black)
+				y += headingHeight
 			}
-			_, widths := c.canv.DrawMultiColorString(timePart+e.Summary, boxLeft+cellPadding, y, columnWidth-cellPadding*2, c.eventFace, cols, canvas.Left)
-			if !e.EndsOnDate(e.StartTime, c.tz) {
-				c.drawCarryoverLine(e, boxLeft+cellPadding+widths[0], y, columnWidth-cellPadding-widths[0], date, col, false)
+			if !startsToday {
+				c.canv.DrawHorizontalArrow(x, y-c.eventFace.Metrics().Ascent.Ceil()/2, int(1.5*float64(font.MeasureString(c.eventFace, " ").Ceil())), canvas.Red, canvas.ArrowLeft)
+			}
+			height, _ := c.canv.DrawMultiColorString(text, x, y, width, c.maxLines(e), c.eventFace, cols, canvas.Left)
+			if location := c.locationLine(e); location != "" {
+				c.canv.DrawString(location, x, y+height+lineHeight, width, c.eventFace, canvas.Black, canvas.Left)
 			}
+			y += h + eventPadding
 		}
 	}
+	if hidden > 0 {
+		x := margin + (agendaColumns-1)*columnWidth + cellPadding
+		c.canv.DrawString(fmt.Sprintf(c.loc.More, hidden), x, c.canv.Height()-margin-c.eventFace.Metrics().Descent.Ceil(), columnWidth-cellPadding*2, c.eventFace, canvas.Red, canvas.Left)
+	}
 }
 
 func (c Calendar) RenderBatteryAndTime(battery float64) {
@@ -182,5 +492,5 @@ func (c Calendar) RenderBatteryAndTime(battery float64) {
 	if battery < 20 {
 		color = canvas.Red
 	}
-	c.canv.DrawString(time.Now().In(c.tz).Format(time.Kitchen)+" | "+fmt.Sprintf("%.0f", battery)+"%", 2, 10, 100, c.batteryFont, color, canvas.Left)
+	c.canv.DrawString(c.loc.Time(time.Now().In(c.tz))+" | "+fmt.Sprintf("%.0f", battery)+"%", 2, 10, 100, c.batteryFont, color, canvas.Left)
 }
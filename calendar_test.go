@@ -0,0 +1,182 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"testing"
+	"time"
+	"wallcalendar/locale"
+)
+
+func TestTimeLabel(t *testing.T) {
+	ny, _ := time.LoadLocation("America/New_York")
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+	e := &Event{StartTime: time.Date(2024, 11, 21, 15, 0, 0, 0, ny)}
+
+	if got := (Calendar{tz: ny, loc: locale.English}).timeLabel(e); got != "3pm " {
+		t.Errorf("timeLabel() = %q", got)
+	}
+	if got := (Calendar{tz: ny, secondaryTZ: berlin, loc: locale.English}).timeLabel(e); got != "3pm (9pm CET) " {
+		t.Errorf("timeLabel() = %q", got)
+	}
+	e.StartTime = time.Date(2024, 11, 21, 19, 30, 0, 0, ny)
+	if got := (Calendar{tz: ny, secondaryTZ: berlin, loc: locale.English}).timeLabel(e); got != "7:30pm (1:30am CET) " {
+		t.Errorf("timeLabel() = %q", got)
+	}
+	de, _ := locale.Get("de", "")
+	if got := (Calendar{tz: ny, secondaryTZ: berlin, loc: de}).timeLabel(e); got != "19:30 (01:30 CET) " {
+		t.Errorf("timeLabel() = %q", got)
+	}
+}
+
+func TestTimeLabelEnd(t *testing.T) {
+	ny, _ := time.LoadLocation("America/New_York")
+	e := &Event{
+		StartTime: time.Date(2024, 11, 21, 15, 0, 0, 0, ny),
+		EndTime:   time.Date(2024, 11, 21, 17, 30, 0, 0, ny),
+	}
+	tests := []struct {
+		label string
+		clock string
+		want  string
+	}{
+		{"range", "", "3–5:30pm "},
+		{"range", "24h", "15:00–17:30 "},
+		{"duration", "", "3pm 2h30m "},
+	}
+	for _, tt := range tests {
+		loc, _ := locale.Get("en", tt.clock)
+		c := Calendar{tz: ny, loc: loc, display: DisplayConfig{TimeLabel: tt.label}}
+		if got := c.timeLabel(e); got != tt.want {
+			t.Errorf("%s %s: timeLabel() = %q, want %q", tt.label, tt.clock, got, tt.want)
+		}
+	}
+
+	// Events ending on another day show their start only.
+	e.EndTime = time.Date(2024, 11, 22, 1, 0, 0, 0, ny)
+	if got := (Calendar{tz: ny, loc: locale.English, display: DisplayConfig{TimeLabel: "range"}}).timeLabel(e); got != "3pm " {
+		t.Errorf("timeLabel() = %q", got)
+	}
+}
+
+func TestUntilLabel(t *testing.T) {
+	ny, _ := time.LoadLocation("America/New_York")
+	first := time.Date(2024, 11, 17, 0, 0, 0, 0, ny)
+	last := time.Date(2024, 11, 30, 0, 0, 0, 0, ny)
+	c := Calendar{tz: ny, loc: locale.English, display: DisplayConfig{ShowUntil: true}}
+	day := func(d int) time.Time { return time.Date(2024, 11, d, 0, 0, 0, 0, ny) }
+	tests := []struct {
+		start, end time.Time
+		want       string
+	}{
+		{day(20), day(27).Add(-time.Second), ""},
+		{day(27), time.Date(2024, 12, 3, 0, 0, 0, 0, ny).Add(-time.Second), "until Mon 12/2 "},
+		{day(14), day(19).Add(-time.Second), "until Mon 11/18 "},
+		{day(20), day(20).Add(time.Hour), ""},
+	}
+	for _, tt := range tests {
+		e := &Event{StartTime: tt.start, EndTime: tt.end}
+		if got := c.untilLabel(e, first, last); got != tt.want {
+			t.Errorf("untilLabel(%v to %v) = %q, want %q", tt.start, tt.end, got, tt.want)
+		}
+	}
+	c.display.ShowUntil = false
+	if got := c.untilLabel(&Event{StartTime: day(14), EndTime: day(19)}, first, last); got != "" {
+		t.Errorf("untilLabel() = %q without show_until", got)
+	}
+}
+
+func TestWeekdays(t *testing.T) {
+	got := Calendar{display: DisplayConfig{WeekStart: "monday"}}.weekdays()
+	if got[0] != time.Monday || got[6] != time.Sunday {
+		t.Errorf("weekdays() = %v, want Monday to Sunday", got)
+	}
+	got = Calendar{}.weekdays()
+	if got[0] != time.Sunday || got[6] != time.Saturday {
+		t.Errorf("weekdays() = %v, want Sunday to Saturday", got)
+	}
+}
+
+func TestRenderMoreLine(t *testing.T) {
+	ny, _ := time.LoadLocation("America/New_York")
+	today := time.Date(2024, 12, 4, 0, 0, 0, 0, ny)
+	window, err := FetchEvents(today, WeekView, time.Sunday, ICalSource{Location: "testdata/layout.ics"}, EventCache{}, nil, ny)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Every height of one slot's worth of canvas heights ends the cell at a
+	// different point between two events.
+	for height := 984; height < 1008; height++ {
+		img := image.NewRGBA(image.Rect(0, 0, 1304, height))
+		c := testCalendarOn(t, img, ny)
+		plan := c.PlanLayout(window, today, WeekView)
+		c.RenderLayout(plan)
+
+		// lastLine counts the red and black pixels where the "+N more"
+		// line of day goes.
+		metrics := c.eventFace.Metrics()
+		lastLine := func(day DayPlan) (red int, black int) {
+			r := day.Rect
+			r.Min.Y = r.Max.Y - metrics.Descent.Ceil() - metrics.Ascent.Ceil()
+			for y := r.Min.Y; y < r.Max.Y; y++ {
+				for x := r.Min.X; x < r.Max.X; x++ {
+					switch img.At(x, y) {
+					case color.RGBA{0xff, 0, 0, 0xff}:
+						red++
+					case color.RGBA{0, 0, 0, 0xff}:
+						black++
+					}
+				}
+			}
+			return red, black
+		}
+
+		// The 48 events of Dec 4 overflow the cell, which ends in a red
+		// "+N more" line clear of the events above it.
+		_, busy := findDay(t, plan, today)
+		if busy.Hidden == 0 {
+			t.Fatalf("height %d: Dec 4 hides no events", height)
+		}
+		if red, black := lastLine(busy); red == 0 || black != 0 {
+			t.Errorf("height %d: last line of Dec 4 has %d red and %d black pixels, want only the red +N more line", height, red, black)
+		}
+		_, quiet := findDay(t, plan, today.AddDate(0, 0, -1))
+		if red, black := lastLine(quiet); quiet.Hidden != 0 || red != 0 || black != 0 {
+			t.Errorf("height %d: Dec 3 hides %d events and its last line has %d red and %d black pixels, want none", height, quiet.Hidden, red, black)
+		}
+	}
+}
+
+func TestRenderDayHeadersCompressed(t *testing.T) {
+	ny, _ := time.LoadLocation("America/New_York")
+	today := time.Date(2024, 11, 21, 0, 0, 0, 0, ny)
+	window, err := FetchEvents(today, TwoWeekView, time.Sunday, ICalSource{Location: "testdata/layout.ics"}, EventCache{}, nil, ny)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img := image.NewRGBA(image.Rect(0, 0, 1304, 984))
+	c := testCalendarOn(t, img, ny)
+	c.display.PastDays = "compress"
+	plan := c.PlanLayout(window, today, TwoWeekView)
+	c.RenderDayHeaders(plan)
+
+	// Every header is drawn within the column of the first row under it.
+	metrics := c.dateFace.Metrics()
+	for _, day := range plan.Rows[0].Days {
+		inside := 0
+		for y := headerHeight - metrics.Ascent.Ceil(); y < headerHeight+metrics.Descent.Ceil(); y++ {
+			for x := day.Rect.Min.X; x < day.Rect.Max.X; x++ {
+				if img.At(x, y) != (color.RGBA{0, 0, 0, 0xff}) {
+					continue
+				}
+				inside++
+				if x == day.Rect.Min.X || x == day.Rect.Max.X-1 {
+					t.Errorf("header of %v reaches the edge of its column %v", day.Date.Weekday(), day.Rect)
+				}
+			}
+		}
+		if inside == 0 {
+			t.Errorf("no header over %v in %v", day.Date.Weekday(), day.Rect)
+		}
+	}
+}
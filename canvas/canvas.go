@@ -5,7 +5,9 @@ import (
 	"image/color"
 	"image/draw"
 	"strings"
+	"unicode"
 
+	"github.com/lovelydeng/uniseg"
 	"golang.org/x/image/font"
 	"golang.org/x/image/math/fixed"
 )
@@ -69,6 +71,7 @@ type Alignment int
 const (
 	Left Alignment = iota + 1
 	Center
+	Right
 )
 
 type ColorSpan struct {
@@ -76,113 +79,149 @@ type ColorSpan struct {
 	Color Color
 }
 
-func (c Canvas) DrawMultiColorString(s string, x int, y int, w int, f font.Face, cols []ColorSpan, a Alignment) (int, []int) {
-	d := &font.Drawer{
-		Dst:  c.dst,
-		Face: f,
+// Ellipsis ends a line cut short by TruncateLines.
+const Ellipsis = "…"
+
+// Line is the text s[Start:End] of one line of a wrapped string s, followed
+// by an Ellipsis if the rest of s is left out.
+type Line struct {
+	Start    int
+	End      int
+	Ellipsis bool
+}
+
+// text returns the text of l in s as drawn.
+func (l Line) text(s string) string {
+	if l.Ellipsis {
+		return s[l.Start:l.End] + Ellipsis
 	}
-	sw := font.MeasureString(f, s)
-	if a == Center {
-		d.Dot = fixed.Point26_6{
-			X: fixed.I(x) + (fixed.I(w)-sw)/2,
-			Y: fixed.I(y),
+	return s[l.Start:l.End]
+}
+
+// BreakLines wraps s into lines no wider than w at the break opportunities
+// of Unicode line breaking (UAX #14), and always after a newline. Trailing
+// spaces are left out of a line. A segment wider than w is broken between
+// grapheme clusters.
+func BreakLines(s string, w int, f font.Face) []Line {
+	fits := func(start int, end int) bool {
+		return font.MeasureString(f, s[start:end]) <= fixed.I(w)
+	}
+	var lines []Line
+	line := Line{}
+	pos, rest, state := 0, s, -1
+	for len(rest) > 0 {
+		var segment string
+		var mustBreak bool
+		segment, rest, mustBreak, state = uniseg.FirstLineSegmentInString(rest, state)
+		text := strings.TrimRightFunc(segment, unicode.IsSpace)
+		if line.End > line.Start && !fits(line.Start, pos+len(text)) {
+			lines = append(lines, line)
+			line = Line{Start: pos, End: pos}
 		}
-	} else {
-		d.Dot = fixed.Point26_6{
-			X: fixed.I(x),
-			Y: fixed.I(y),
+		if fits(line.Start, pos+len(text)) {
+			line.End = pos + len(text)
+		} else {
+			g := uniseg.NewGraphemes(text)
+			for g.Next() {
+				from, to := g.Positions()
+				if line.End > line.Start && !fits(line.Start, pos+to) {
+					lines = append(lines, line)
+					line = Line{Start: pos + from, End: pos + from}
+				}
+				line.End = pos + to
+			}
+		}
+		pos += len(segment)
+		if mustBreak && len(rest) > 0 {
+			lines = append(lines, line)
+			line = Line{Start: pos, End: pos}
 		}
 	}
+	return append(lines, line)
+}
 
-	words := strings.Split(s, " ")
-	widths := []int{}
-	colorIndex := 0
-	stringIndex := 0
-	for _, word := range words {
-		if colorIndex < len(cols) {
-			if cols[colorIndex].Start <= stringIndex {
-				d.Src = cols[colorIndex].Color.ToImage()
-				colorIndex += 1
-			}
-		}
-		width := font.MeasureString(f, word)
-		if d.Dot.X+width-fixed.I(x) > fixed.I(w) {
-			widths = append(widths, d.Dot.X.Round()-x)
-			d.Dot.X = fixed.I(x)
-			d.Dot.Y += fixed.I(f.Metrics().Height.Ceil())
+// TruncateLines keeps the first maxLines of the lines of s, ending the last
+// one with an Ellipsis that still fits the width w if any are left out. A
+// maxLines of 0 keeps every line.
+func TruncateLines(s string, lines []Line, maxLines int, w int, f font.Face) []Line {
+	if maxLines <= 0 || len(lines) <= maxLines {
+		return lines
+	}
+	rest := s[lines[maxLines-1].Start:lines[len(lines)-1].End]
+	lines = append([]Line(nil), lines[:maxLines]...)
+	// The last line takes as much of the rest as fits before the ellipsis,
+	// cut between grapheme clusters.
+	last := &lines[maxLines-1]
+	last.Ellipsis = true
+	end := 0
+	g := uniseg.NewGraphemes(rest)
+	for g.Next() {
+		_, to := g.Positions()
+		if font.MeasureString(f, rest[:to]+Ellipsis) > fixed.I(w) {
+			break
 		}
-		d.DrawString(word + " ")
-		stringIndex += len(word + " ")
+		end = to
 	}
-	widths = append(widths, d.Dot.X.Round()-x)
-	return d.Dot.Y.Round() - y, widths
+	last.End = last.Start + len(strings.TrimRightFunc(rest[:end], unicode.IsSpace))
+	return lines
 }
 
-func (c Canvas) MeasureMultiColorString(s string, w int, f font.Face) int {
-	// We use a dummy drawer just to measure
+// DrawLines draws the lines of s with their first baseline at y, each line
+// aligned by a within the w pixels from x. The colors of cols apply from
+// their byte offset in s on. It returns the width of every line from x,
+// including a trailing space.
+func (c Canvas) DrawLines(s string, lines []Line, x int, y int, w int, f font.Face, cols []ColorSpan, a Alignment) []int {
 	d := &font.Drawer{
+		Dst:  c.dst,
+		Src:  Black.ToImage(),
 		Face: f,
 	}
-	// Starting dot doesn't matter much for height relative to start, but let's keep it simple
-	d.Dot = fixed.Point26_6{
-		X: fixed.I(0),
-		Y: fixed.I(0),
-	}
-
-	words := strings.Split(s, " ")
-	for _, word := range words {
-		width := font.MeasureString(f, word)
-		if d.Dot.X+width > fixed.I(w) {
-			d.Dot.X = fixed.I(0)
-			d.Dot.Y += fixed.I(f.Metrics().Height.Ceil())
+	widths := make([]int, len(lines))
+	for n, line := range lines {
+		text := line.text(s)
+		d.Dot = fixed.Point26_6{X: fixed.I(x), Y: fixed.I(y + n*f.Metrics().Height.Ceil())}
+		switch a {
+		case Center:
+			d.Dot.X += (fixed.I(w) - font.MeasureString(f, text)) / 2
+		case Right:
+			d.Dot.X += fixed.I(w) - font.MeasureString(f, text)
+		}
+		// Draw the line in runs of one color.
+		for pos := line.Start; pos < line.End; {
+			next := line.End
+			for _, col := range cols {
+				if col.Start <= pos {
+					d.Src = col.Color.ToImage()
+				} else if col.Start < next {
+					next = col.Start
+				}
+			}
+			d.DrawString(s[pos:next])
+			pos = next
 		}
-		// Simulate drawing
-		d.Dot.X += font.MeasureString(f, word + " ")
-	}
-	// The height is the final Y + one line height (since we start at 0 and add height for new lines)
-	// Actually, DrawMultiColorString returns d.Dot.Y.Round() - y.
-	// If we start at y=0, it returns d.Dot.Y.Round().
-	// However, the first line sits at y=0 (baseline? no, usually top-left for this canvas lib?).
-	// Let's look at DrawMultiColorString:
-	// It adds Height.Ceil() when wrapping.
-	// So if 1 line, Y is 0. Return is 0?
-	// Wait, DrawMultiColorString returns `d.Dot.Y.Round() - y`.
-	// If no wrap, Y stays at y. Return is 0.
-	// But the caller usually adds `height` to `y`.
-	// If return is 0, `y += 0`. That seems wrong for the next event.
-	// Ah, `DrawMultiColorString` logic in `canvas.go`:
-	// `d.Dot.Y += fixed.I(f.Metrics().Height.Ceil())` on wrap.
-	// So if 1 line, Y is unchanged.
-	// But `Render` does `y += height`.
-	// If height is 0, lines overlap.
-	// Let's check `Render` in `calendar.go` before this change.
-	// `height, widths := c.canv.DrawMultiColorString(...)`
-	// `y += height`
-	// `y += int(float64(c.eventFace.Metrics().Height.Round()) * 1.5)`
-	// So `height` is the *additional* height from wrapping?
-	// If `DrawMultiColorString` returns 0 for single line, then `y` increments by the fixed amount (1.5 * line height).
-	// So `MeasureMultiColorString` should return the *additional* height too?
-	// Or should it return the *total* height?
-	// The plan says "calculate the maximum height required".
-	// If I want total height, I should probably return (lines * line_height).
-	// Let's stick to what DrawMultiColorString returns for consistency, but we need to know the *total* visual height to reserve space.
-	// Actually, `DrawMultiColorString` returns the Y delta.
-	// If I have 2 lines, Y increases by 1 line height.
-	// So return value is `(lines - 1) * lineHeight`.
-	// We probably want the full height: `lines * lineHeight`.
-	// Let's adjust `MeasureMultiColorString` to return the full height in pixels.
-
-	lines := 1
-	currentX := fixed.I(0)
-	for _, word := range words {
-		width := font.MeasureString(f, word)
-		if currentX+width > fixed.I(w) {
-			lines++
-			currentX = fixed.I(0)
+		if line.Ellipsis {
+			d.DrawString(Ellipsis)
 		}
-		currentX += font.MeasureString(f, word + " ")
+		d.DrawString(" ")
+		widths[n] = d.Dot.X.Round() - x
 	}
-	return lines * f.Metrics().Height.Ceil()
+	return widths
+}
+
+// DrawMultiColorString draws s wrapped to the width w in at most maxLines
+// lines (0 for any number) with its first baseline at y, each line aligned
+// by a. It returns how far below y the last baseline is and the width of
+// every line.
+func (c Canvas) DrawMultiColorString(s string, x int, y int, w int, maxLines int, f font.Face, cols []ColorSpan, a Alignment) (int, []int) {
+	lines := TruncateLines(s, BreakLines(s, w, f), maxLines, w, f)
+	widths := c.DrawLines(s, lines, x, y, w, f, cols, a)
+	return (len(lines) - 1) * f.Metrics().Height.Ceil(), widths
+}
+
+// MeasureMultiColorString returns the height of s wrapped to the width w in
+// at most maxLines lines, 0 for any number.
+func (c Canvas) MeasureMultiColorString(s string, w int, maxLines int, f font.Face) int {
+	return len(TruncateLines(s, BreakLines(s, w, f), maxLines, w, f)) * f.Metrics().Height.Ceil()
 }
 
 func (c Canvas) DrawString(s string, x int, y int, w int, f font.Face, col Color, a Alignment) (int, []int) {
@@ -192,7 +231,12 @@ func (c Canvas) DrawString(s string, x int, y int, w int, f font.Face, col Color
 			Color: col,
 		},
 	}
-	return c.DrawMultiColorString(s, x, y, w, f, cols, a)
+	return c.DrawMultiColorString(s, x, y, w, 0, f, cols, a)
+}
+
+// FillRect fills r with col.
+func (c Canvas) FillRect(r image.Rectangle, col Color) {
+	draw.Draw(c.dst, r, col.ToImage(), image.Point{}, draw.Src)
 }
 
 func (c Canvas) DrawCircle(x int, y int, radius int, col Color) {
@@ -205,6 +249,36 @@ func (c Canvas) DrawCircle(x int, y int, radius int, col Color) {
 	}
 }
 
+// Fade greys out whatever is drawn in r by whitening every other pixel.
+func (c Canvas) Fade(r image.Rectangle) {
+	r = r.Intersect(c.dst.Bounds())
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X + (r.Min.X+y)%2; x < r.Max.X; x += 2 {
+			c.dst.Set(x, y, White.ToColor())
+		}
+	}
+}
+
+// Hatch draws diagonal lines spacing pixels apart over r in col.
+func (c Canvas) Hatch(r image.Rectangle, spacing int, col Color) {
+	r = r.Intersect(c.dst.Bounds())
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X + (spacing-(r.Min.X+y)%spacing)%spacing; x < r.Max.X; x += spacing {
+			c.dst.Set(x, y, col.ToColor())
+		}
+	}
+}
+
+// Stipple draws a grid of dots spacing pixels apart over r in col.
+func (c Canvas) Stipple(r image.Rectangle, spacing int, col Color) {
+	r = r.Intersect(c.dst.Bounds())
+	for y := r.Min.Y + spacing/2; y < r.Max.Y; y += spacing {
+		for x := r.Min.X + spacing/2; x < r.Max.X; x += spacing {
+			c.dst.Set(x, y, col.ToColor())
+		}
+	}
+}
+
 func (c Canvas) Width() int {
 	return c.dst.Bounds().Dx()
 }
@@ -0,0 +1,156 @@
+package canvas
+
+import (
+	"image"
+	"strings"
+	"testing"
+
+	"github.com/furconz/freetype/truetype"
+	"github.com/lovelydeng/uniseg"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/math/fixed"
+)
+
+func testFace(t *testing.T) font.Face {
+	t.Helper()
+	f, err := truetype.Parse(gomono.TTF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return truetype.NewFace(f, &truetype.Options{Size: 16, DPI: 72, Hinting: font.HintingFull})
+}
+
+func TestTruncateLines(t *testing.T) {
+	f := testFace(t)
+	s := "Parent teacher conference about the science fair project"
+	w := font.MeasureString(f, "Parent teacher").Ceil()
+	lines := BreakLines(s, w, f)
+	if len(lines) < 3 {
+		t.Fatalf("got %d lines, want at least 3", len(lines))
+	}
+
+	if got := TruncateLines(s, lines, 0, w, f); len(got) != len(lines) {
+		t.Errorf("no limit kept %d of %d lines", len(got), len(lines))
+	}
+	if got := TruncateLines(s, lines, len(lines), w, f); got[len(got)-1].Ellipsis {
+		t.Errorf("the last line of text that fits ends in an ellipsis")
+	}
+
+	got := TruncateLines(s, lines, 2, w, f)
+	if len(got) != 2 || got[0].Ellipsis || !got[1].Ellipsis {
+		t.Fatalf("TruncateLines(2) = %+v", got)
+	}
+	if lines[1].Ellipsis {
+		t.Errorf("TruncateLines changed its input")
+	}
+	for _, line := range got {
+		if width := font.MeasureString(f, line.text(s)); width > fixed.I(w) {
+			t.Errorf("line %q is wider than %d", line.text(s), w)
+		}
+	}
+	if text := got[1].text(s); text != "conference ab…" {
+		t.Errorf("last line = %q", text)
+	}
+
+	// The ellipsis is measured and drawn as part of the text.
+	c := NewCanvas(image.NewRGBA(image.Rect(0, 0, 200, 100)))
+	if h := c.MeasureMultiColorString(s, w, 2, f); h != 2*f.Metrics().Height.Ceil() {
+		t.Errorf("MeasureMultiColorString() = %d, want 2 lines", h)
+	}
+	_, widths := c.DrawMultiColorString(s, 0, 20, w, 2, f, []ColorSpan{{Start: 0, Color: Black}}, Left)
+	if want := font.MeasureString(f, got[1].text(s)+" ").Round(); widths[1] != want {
+		t.Errorf("drew the last line %d wide, want %d", widths[1], want)
+	}
+}
+
+func TestTruncateLinesMultibyte(t *testing.T) {
+	f := testFace(t)
+	s := "Überraschungsgeburtstagsfeier für Jürgen"
+	w := font.MeasureString(f, "Überraschungs").Ceil()
+	got := TruncateLines(s, BreakLines(s, w, f), 1, w, f)
+	if len(got) != 1 || !got[0].Ellipsis {
+		t.Fatalf("TruncateLines(1) = %+v", got)
+	}
+	text := got[0].text(s)
+	if font.MeasureString(f, text) > fixed.I(w) {
+		t.Errorf("line %q is wider than %d", text, w)
+	}
+	if text[:len("Über")] != "Über" {
+		t.Errorf("line = %q, want it to start with Über", text)
+	}
+}
+
+// checkLines fails if lines are not in order, are wider than w while holding
+// more than one grapheme cluster, split a cluster or, unless truncated, leave
+// out anything of s but spaces.
+func checkLines(t *testing.T, s string, lines []Line, w int, f font.Face, truncated bool) {
+	t.Helper()
+	boundaries := map[int]bool{len(s): true}
+	g := uniseg.NewGraphemes(s)
+	for g.Next() {
+		from, _ := g.Positions()
+		boundaries[from] = true
+	}
+	covered := 0
+	for _, line := range lines {
+		text := s[line.Start:line.End]
+		if line.Start < covered || !boundaries[line.Start] || !boundaries[line.End] {
+			t.Errorf("line %q at %d-%d splits a cluster or overlaps", text, line.Start, line.End)
+		}
+		if !truncated && strings.TrimSpace(s[covered:line.Start]) != "" {
+			t.Errorf("%q is left out before line %q", s[covered:line.Start], text)
+		}
+		if font.MeasureString(f, line.text(s)) > fixed.I(w) && uniseg.GraphemeClusterCount(text) > 1 {
+			t.Errorf("line %q is wider than %d", text, w)
+		}
+		covered = line.End
+	}
+	if !truncated && strings.TrimSpace(s[covered:]) != "" {
+		t.Errorf("%q is left out at the end", s[covered:])
+	}
+}
+
+func TestBreakLines(t *testing.T) {
+	f := testFace(t)
+	chars := func(n int) int { return font.MeasureString(f, strings.Repeat("x", n)).Ceil() }
+	tests := []struct {
+		name string
+		s    string
+		w    int
+		want []string
+	}{
+		{"spaces", "Beach trip with the whole family", chars(10), []string{"Beach trip", "with the", "whole", "family"}},
+		{"leading spaces", "  Beach trip", chars(9), []string{"  Beach", "trip"}},
+		{"newline", "Swim\npractice", chars(40), []string{"Swim", "practice"}},
+		{"hyphen", "Jean-Pierre Dupont", chars(8), []string{"Jean-", "Pierre", "Dupont"}},
+		{"url", "https://example.com/calendars/family", chars(20), []string{"https://example.com/", "calendars/family"}},
+		{"long word", "Donaudampfschifffahrt", chars(8), []string{"Donaudam", "pfschiff", "fahrt"}},
+		{"cjk", "東京で会議があります。", chars(4), []string{"東京で会", "議があり", "ます。"}},
+		{"mixed", "Lunch 東京 café", chars(7), []string{"Lunch 東", "京 café"}},
+		{"emoji", "Party 🎉🎂 time", chars(7), []string{"Party 🎉", "🎂 time"}},
+	}
+	for _, tt := range tests {
+		lines := BreakLines(tt.s, tt.w, f)
+		checkLines(t, tt.s, lines, tt.w, f, false)
+		var got []string
+		for _, line := range lines {
+			got = append(got, tt.s[line.Start:line.End])
+		}
+		if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("%s: BreakLines(%q) = %q, want %q", tt.name, tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestBreakLinesGraphemes(t *testing.T) {
+	f := testFace(t)
+	// A family, flags and an accent written as a combining mark are single
+	// clusters even in a column narrower than one glyph.
+	for _, s := range []string{"👨‍👩‍👧‍👦👨‍👩‍👧‍👦", "🇩🇪🇫🇷🇯🇵", "Cafe\u0301s", "👍🏽👍🏽👍🏽"} {
+		for _, w := range []int{1, 20, 40} {
+			checkLines(t, s, BreakLines(s, w, f), w, f, false)
+			checkLines(t, s, TruncateLines(s, BreakLines(s, w, f), 1, w, f), w, f, true)
+		}
+	}
+}
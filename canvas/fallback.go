@@ -0,0 +1,60 @@
+package canvas
+
+import (
+	"image"
+
+	"github.com/AndreKR/multiface"
+	"github.com/furconz/freetype/truetype"
+	"golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
+)
+
+// FallbackFace returns a face of fonts at opts that draws and measures every
+// glyph with the first font that has it, e.g. a text, a symbol and an emoji
+// font. Its metrics are those of the first font, so lines keep their height
+// whatever glyphs they hold. Glyphs none of the fonts has are drawn as the
+// missing glyph box of the first.
+func FallbackFace(opts *truetype.Options, fonts ...*truetype.Font) font.Face {
+	var faces []font.Face
+	for _, f := range fonts {
+		faces = append(faces, glyphFace{Face: truetype.NewFace(f, opts), font: f})
+	}
+	return fallback(append(faces, truetype.NewFace(fonts[0], opts))...)
+}
+
+// fallback returns a face using the first of faces that has a glyph.
+func fallback(faces ...font.Face) font.Face {
+	face := new(multiface.Face)
+	for _, f := range faces {
+		face.AddFace(f)
+	}
+	return face
+}
+
+// glyphFace is a face of font that reports the glyphs the font lacks, which
+// truetype faces do not.
+type glyphFace struct {
+	font.Face
+	font *truetype.Font
+}
+
+func (f glyphFace) Glyph(dot fixed.Point26_6, r rune) (image.Rectangle, image.Image, image.Point, fixed.Int26_6, bool) {
+	if f.font.Index(r) == 0 {
+		return image.Rectangle{}, nil, image.Point{}, 0, false
+	}
+	return f.Face.Glyph(dot, r)
+}
+
+func (f glyphFace) GlyphBounds(r rune) (fixed.Rectangle26_6, fixed.Int26_6, bool) {
+	if f.font.Index(r) == 0 {
+		return fixed.Rectangle26_6{}, 0, false
+	}
+	return f.Face.GlyphBounds(r)
+}
+
+func (f glyphFace) GlyphAdvance(r rune) (fixed.Int26_6, bool) {
+	if f.font.Index(r) == 0 {
+		return 0, false
+	}
+	return f.Face.GlyphAdvance(r)
+}
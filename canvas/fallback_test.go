@@ -0,0 +1,90 @@
+package canvas
+
+import (
+	"image"
+	"testing"
+
+	"github.com/furconz/freetype/truetype"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/math/fixed"
+)
+
+// digitFace only has the glyphs of digits.
+type digitFace struct {
+	font.Face
+}
+
+func (f digitFace) Glyph(dot fixed.Point26_6, r rune) (image.Rectangle, image.Image, image.Point, fixed.Int26_6, bool) {
+	if r < '0' || r > '9' {
+		return image.Rectangle{}, nil, image.Point{}, 0, false
+	}
+	return f.Face.Glyph(dot, r)
+}
+
+func (f digitFace) GlyphBounds(r rune) (fixed.Rectangle26_6, fixed.Int26_6, bool) {
+	if r < '0' || r > '9' {
+		return fixed.Rectangle26_6{}, 0, false
+	}
+	return f.Face.GlyphBounds(r)
+}
+
+func (f digitFace) GlyphAdvance(r rune) (fixed.Int26_6, bool) {
+	if r < '0' || r > '9' {
+		return 0, false
+	}
+	return f.Face.GlyphAdvance(r)
+}
+
+func TestFallback(t *testing.T) {
+	mono := testFace(t)
+	reg, err := truetype.Parse(goregular.TTF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	regular := truetype.NewFace(reg, &truetype.Options{Size: 16, DPI: 72, Hinting: font.HintingFull})
+	face := fallback(digitFace{mono}, regular)
+
+	// Digits come from the first face, the rest from the second, both in
+	// drawing and measuring.
+	if got, want := font.MeasureString(face, "1"), font.MeasureString(mono, "1"); got != want {
+		t.Errorf("1 is %v wide, want %v", got, want)
+	}
+	if got, want := font.MeasureString(face, "i"), font.MeasureString(regular, "i"); got != want {
+		t.Errorf("i is %v wide, want %v", got, want)
+	}
+	img := image.NewRGBA(image.Rect(0, 0, 100, 30))
+	d := &font.Drawer{Dst: img, Src: image.Black, Face: face, Dot: fixed.P(0, 20)}
+	d.DrawString("1i")
+	if want := font.MeasureString(mono, "1") + font.MeasureString(regular, "i"); d.Dot.X != want {
+		t.Errorf("drew 1i %v wide, want %v", d.Dot.X, want)
+	}
+	if face.Metrics() != mono.Metrics() {
+		t.Errorf("metrics are not those of the first face")
+	}
+}
+
+func TestFallbackFace(t *testing.T) {
+	f, err := truetype.Parse(goregular.TTF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := &truetype.Options{Size: 16, DPI: 72}
+	plain := truetype.NewFace(f, opts)
+
+	// The font reports the glyphs it lacks, so the next one can draw them.
+	g := glyphFace{Face: plain, font: f}
+	if _, ok := g.GlyphAdvance('A'); !ok {
+		t.Errorf("A is missing")
+	}
+	if _, ok := g.GlyphAdvance('🎉'); ok {
+		t.Errorf("🎉 is not missing")
+	}
+
+	// Glyphs no font has are drawn as the missing glyph box, so wrapping
+	// counts them.
+	face := FallbackFace(opts, f, f)
+	if got, want := font.MeasureString(face, "A🎉"), font.MeasureString(plain, "A🎉"); got != want || got <= font.MeasureString(plain, "A") {
+		t.Errorf("A🎉 is %v wide, want %v", got, want)
+	}
+}
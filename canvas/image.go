@@ -0,0 +1,129 @@
+package canvas
+
+import (
+	"image"
+
+	xdraw "golang.org/x/image/draw"
+)
+
+// Dither is how DrawImage reduces an image to black, red and white.
+type Dither int
+
+const (
+	// Threshold takes the nearest color of every pixel.
+	Threshold Dither = iota + 1
+	// Bayer adds the pattern of a 4x4 Bayer matrix before taking the
+	// nearest color.
+	Bayer
+	// FloydSteinberg spreads the error of every pixel to the pixels right
+	// and below it.
+	FloydSteinberg
+	// Atkinson spreads three quarters of the error further but thinner,
+	// keeping more contrast.
+	Atkinson
+)
+
+// bayer4 is the 4x4 Bayer threshold matrix.
+var bayer4 = [4][4]float32{
+	{0, 8, 2, 10},
+	{12, 4, 14, 6},
+	{3, 11, 1, 9},
+	{15, 7, 13, 5},
+}
+
+// diffusion is the share of the error of a pixel given to the pixel dx, dy
+// from it.
+type diffusion struct {
+	dx, dy int
+	share  float32
+}
+
+var diffusions = map[Dither][]diffusion{
+	FloydSteinberg: {{1, 0, 7. / 16}, {-1, 1, 3. / 16}, {0, 1, 5. / 16}, {1, 1, 1. / 16}},
+	Atkinson:       {{1, 0, 1. / 8}, {2, 0, 1. / 8}, {-1, 1, 1. / 8}, {0, 1, 1. / 8}, {1, 1, 1. / 8}, {0, 2, 1. / 8}},
+}
+
+// palette are the colors an image is reduced to, with their RGB values.
+var palette = []struct {
+	col Color
+	rgb [3]float32
+}{
+	{Black, [3]float32{0, 0, 0}},
+	{White, [3]float32{255, 255, 255}},
+	{Red, [3]float32{255, 0, 0}},
+}
+
+// nearest returns the palette entry closest to rgb.
+func nearest(rgb [3]float32) (Color, [3]float32) {
+	best, bestDist := 0, float32(-1)
+	for i, p := range palette {
+		var dist float32
+		for ch := range rgb {
+			d := rgb[ch] - p.rgb[ch]
+			dist += d * d
+		}
+		if bestDist < 0 || dist < bestDist {
+			best, bestDist = i, dist
+		}
+	}
+	return palette[best].col, palette[best].rgb
+}
+
+// fitRect returns the largest rectangle with the aspect ratio of src centered
+// in r.
+func fitRect(src image.Rectangle, r image.Rectangle) image.Rectangle {
+	w, h := r.Dx(), r.Dy()
+	if src.Dx()*h > src.Dy()*w {
+		h = src.Dy() * w / src.Dx()
+	} else {
+		w = src.Dx() * h / src.Dy()
+	}
+	topLeft := r.Min.Add(image.Pt((r.Dx()-w)/2, (r.Dy()-h)/2))
+	return image.Rectangle{Min: topLeft, Max: topLeft.Add(image.Pt(w, h))}
+}
+
+// DrawImage scales src to the largest size with its aspect ratio that fits r,
+// centered in it, and draws it reduced to black, red and white by dither.
+// Pixels that are more than half transparent are left as they are.
+func (c Canvas) DrawImage(src image.Image, r image.Rectangle, dither Dither) {
+	if src.Bounds().Empty() || r.Empty() {
+		return
+	}
+	r = fitRect(src.Bounds(), r)
+	w, h := r.Dx(), r.Dy()
+	scaled := image.NewNRGBA(image.Rect(0, 0, w, h))
+	xdraw.CatmullRom.Scale(scaled, scaled.Bounds(), src, src.Bounds(), xdraw.Src, nil)
+
+	pix := make([][3]float32, w*h)
+	for i := range pix {
+		p := scaled.Pix[i*4 : i*4+3]
+		pix[i] = [3]float32{float32(p[0]), float32(p[1]), float32(p[2])}
+	}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			i := y*w + x
+			if scaled.Pix[i*4+3] < 0x80 {
+				continue
+			}
+			rgb := pix[i]
+			if dither == Bayer {
+				offset := (bayer4[y%4][x%4]+0.5)/16*255 - 127.5
+				for ch := range rgb {
+					rgb[ch] += offset
+				}
+			}
+			col, got := nearest(rgb)
+			c.dst.Set(r.Min.X+x, r.Min.Y+y, col.ToColor())
+
+			for _, d := range diffusions[dither] {
+				nx, ny := x+d.dx, y+d.dy
+				if nx < 0 || nx >= w || ny >= h {
+					continue
+				}
+				for ch := range rgb {
+					pix[ny*w+nx][ch] += (rgb[ch] - got[ch]) * d.share
+				}
+			}
+		}
+	}
+}
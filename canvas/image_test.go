@@ -0,0 +1,103 @@
+package canvas
+
+import (
+	"image"
+	"image/color"
+	"testing"
+	"wallcalendar/waveshare"
+)
+
+func uniform(w int, h int, c color.Color) image.Image {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+// count returns how many pixels of r in img are black, red and white.
+func count(img *waveshare.HorizontalLSB, r image.Rectangle) map[waveshare.Bit]int {
+	n := make(map[waveshare.Bit]int)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			n[img.BitAt(x, y)]++
+		}
+	}
+	return n
+}
+
+func TestDrawImageThreshold(t *testing.T) {
+	for c, want := range map[color.Color]waveshare.Bit{
+		color.NRGBA{0x20, 0x20, 0x20, 0xff}: waveshare.BlackBit,
+		color.NRGBA{0xe0, 0xe0, 0xd0, 0xff}: waveshare.WhiteBit,
+		color.NRGBA{0xd0, 0x30, 0x20, 0xff}: waveshare.RedBit,
+	} {
+		img := waveshare.NewHorizontalLSB(image.Rect(0, 0, 64, 64))
+		NewCanvas(img).DrawImage(uniform(8, 8, c), img.Rect, Threshold)
+		if n := count(img, img.Rect); n[want] != 64*64 {
+			t.Errorf("%v drew %v, want all %v", c, n, want)
+		}
+	}
+}
+
+func TestDrawImageDither(t *testing.T) {
+	gray := uniform(16, 16, color.NRGBA{0x80, 0x80, 0x80, 0xff})
+	for _, d := range []Dither{Bayer, FloydSteinberg, Atkinson} {
+		img := waveshare.NewHorizontalLSB(image.Rect(0, 0, 64, 64))
+		NewCanvas(img).DrawImage(gray, img.Rect, d)
+		n := count(img, img.Rect)
+		// Mid gray is a mix of black and white, about half each.
+		if n[waveshare.RedBit] > 64*64/20 || n[waveshare.BlackBit] < 64*64/3 || n[waveshare.WhiteBit] < 64*64/3 {
+			t.Errorf("dither %d drew gray as %v", d, n)
+		}
+	}
+
+	// Light gray is mostly white, dark gray mostly black.
+	img := waveshare.NewHorizontalLSB(image.Rect(0, 0, 64, 64))
+	c := NewCanvas(img)
+	c.DrawImage(uniform(8, 8, color.NRGBA{0xc0, 0xc0, 0xc0, 0xff}), image.Rect(0, 0, 32, 32), FloydSteinberg)
+	c.DrawImage(uniform(8, 8, color.NRGBA{0x40, 0x40, 0x40, 0xff}), image.Rect(32, 0, 64, 32), FloydSteinberg)
+	if light, dark := count(img, image.Rect(0, 0, 32, 32)), count(img, image.Rect(32, 0, 64, 32)); light[waveshare.WhiteBit] < 32*32*2/3 || dark[waveshare.BlackBit] < 32*32*2/3 {
+		t.Errorf("light gray drew %v and dark gray %v", light, dark)
+	}
+}
+
+func TestDrawImageFits(t *testing.T) {
+	img := waveshare.NewHorizontalLSB(image.Rect(0, 0, 64, 64))
+	c := NewCanvas(img)
+	c.FillRect(img.Rect, Red)
+
+	// A wide black image fills a band across the middle; the rest is left
+	// as it was.
+	c.DrawImage(uniform(20, 10, color.Black), image.Rect(8, 8, 48, 48), Atkinson)
+	if n := count(img, image.Rect(8, 18, 48, 38)); n[waveshare.BlackBit] != 40*20 {
+		t.Errorf("band = %v, want all black", n)
+	}
+	if n := count(img, image.Rect(8, 8, 48, 18)); n[waveshare.RedBit] != 40*10 {
+		t.Errorf("above the band = %v, want it untouched", n)
+	}
+
+	// Transparent pixels are not drawn.
+	transparent := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	c.DrawImage(transparent, img.Rect, FloydSteinberg)
+	if n := count(img, image.Rect(0, 0, 8, 8)); n[waveshare.RedBit] != 64 {
+		t.Errorf("transparent image drew %v", n)
+	}
+}
+
+func TestFitRect(t *testing.T) {
+	tests := []struct {
+		src, r, want image.Rectangle
+	}{
+		{image.Rect(0, 0, 20, 10), image.Rect(0, 0, 40, 40), image.Rect(0, 10, 40, 30)},
+		{image.Rect(0, 0, 10, 20), image.Rect(10, 10, 50, 50), image.Rect(20, 10, 40, 50)},
+		{image.Rect(5, 5, 15, 15), image.Rect(0, 0, 30, 30), image.Rect(0, 0, 30, 30)},
+	}
+	for _, tt := range tests {
+		if got := fitRect(tt.src, tt.r); got != tt.want {
+			t.Errorf("fitRect(%v, %v) = %v, want %v", tt.src, tt.r, got, tt.want)
+		}
+	}
+}
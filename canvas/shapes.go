@@ -0,0 +1,219 @@
+package canvas
+
+import (
+	"image"
+	"math"
+	"sort"
+)
+
+// Stroke is how lines and outlines are drawn.
+type Stroke struct {
+	Color Color
+	// Width is the width of the line in pixels, 1 if 0.
+	Width int
+	// Dash alternates the lengths in pixels of the drawn and skipped parts
+	// of the line, e.g. {6, 4} for dashes or {1, 2} for dots. Lines are
+	// solid if it is empty.
+	Dash []int
+}
+
+var (
+	// Dashed is the Dash of a dashed line.
+	Dashed = []int{6, 4}
+	// Dotted is the Dash of a dotted line.
+	Dotted = []int{1, 2}
+)
+
+func (s Stroke) width() int {
+	return max(s.Width, 1)
+}
+
+// on reports whether the line is drawn at pos pixels from its start.
+func (s Stroke) on(pos float64) bool {
+	period := 0
+	for _, n := range s.Dash {
+		period += n
+	}
+	if period == 0 {
+		return true
+	}
+	p := int(pos) % period
+	for i, n := range s.Dash {
+		if p < n {
+			return i%2 == 0
+		}
+		p -= n
+	}
+	return true
+}
+
+// DrawLine draws a line from x0, y0 to x1, y1 at any angle, centered on
+// the points between them.
+func (c Canvas) DrawLine(x0 int, y0 int, x1 int, y1 int, s Stroke) {
+	dx, dy := float64(x1-x0), float64(y1-y0)
+	length := math.Hypot(dx, dy)
+	steps := max(abs(x1-x0), abs(y1-y0))
+	w := s.width()
+	for i := 0; i <= steps; i++ {
+		t := 0.0
+		if steps > 0 {
+			t = float64(i) / float64(steps)
+		}
+		if !s.on(t * length) {
+			continue
+		}
+		x := x0 + int(math.Round(t*dx))
+		y := y0 + int(math.Round(t*dy))
+		if w == 1 {
+			c.dst.Set(x, y, s.Color.ToColor())
+			continue
+		}
+		c.fillDisc(float64(x)+0.5, float64(y)+0.5, float64(w)/2, s.Color)
+	}
+}
+
+// fillDisc fills the pixels whose centers are within radius of cx, cy.
+func (c Canvas) fillDisc(cx float64, cy float64, radius float64, col Color) {
+	for y := int(math.Floor(cy - radius)); y <= int(math.Ceil(cy+radius)); y++ {
+		for x := int(math.Floor(cx - radius)); x <= int(math.Ceil(cx+radius)); x++ {
+			if math.Hypot(float64(x)+0.5-cx, float64(y)+0.5-cy) <= radius {
+				c.dst.Set(x, y, col.ToColor())
+			}
+		}
+	}
+}
+
+// DrawRect draws the outline of r inside it, with corners rounded to radius
+// if it is above 0.
+func (c Canvas) DrawRect(r image.Rectangle, radius int, s Stroke) {
+	radius = min(radius, r.Dx()/2, r.Dy()/2)
+	w := s.width()
+	c.strokeBand(image.Rect(r.Min.X+radius, r.Min.Y, r.Max.X-radius, r.Min.Y+w), true, s)
+	c.strokeBand(image.Rect(r.Min.X+radius, r.Max.Y-w, r.Max.X-radius, r.Max.Y), true, s)
+	c.strokeBand(image.Rect(r.Min.X, r.Min.Y+radius, r.Min.X+w, r.Max.Y-radius), false, s)
+	c.strokeBand(image.Rect(r.Max.X-w, r.Min.Y+radius, r.Max.X, r.Max.Y-radius), false, s)
+	if radius == 0 {
+		return
+	}
+	x0, y0 := r.Min.X+radius, r.Min.Y+radius
+	x1, y1 := r.Max.X-radius, r.Max.Y-radius
+	c.DrawArc(x1, y0, radius, 270, 360, s)
+	c.DrawArc(x1, y1, radius, 0, 90, s)
+	c.DrawArc(x0, y1, radius, 90, 180, s)
+	c.DrawArc(x0, y0, radius, 180, 270, s)
+}
+
+// strokeBand fills the band b, a straight side of an outline running
+// horizontally or not, where the stroke s is on.
+func (c Canvas) strokeBand(b image.Rectangle, horizontal bool, s Stroke) {
+	if horizontal {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if s.on(float64(x - b.Min.X)) {
+				c.FillRect(image.Rect(x, b.Min.Y, x+1, b.Max.Y), s.Color)
+			}
+		}
+		return
+	}
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		if s.on(float64(y - b.Min.Y)) {
+			c.FillRect(image.Rect(b.Min.X, y, b.Max.X, y+1), s.Color)
+		}
+	}
+}
+
+// FillRoundedRect fills r with col, with corners rounded to radius.
+func (c Canvas) FillRoundedRect(r image.Rectangle, radius int, col Color) {
+	radius = min(radius, r.Dx()/2, r.Dy()/2)
+	if radius <= 0 {
+		c.FillRect(r, col)
+		return
+	}
+	c.FillRect(image.Rect(r.Min.X+radius, r.Min.Y, r.Max.X-radius, r.Max.Y), col)
+	c.FillRect(image.Rect(r.Min.X, r.Min.Y+radius, r.Max.X, r.Max.Y-radius), col)
+	for _, p := range []image.Point{
+		{r.Min.X + radius, r.Min.Y + radius},
+		{r.Max.X - radius, r.Min.Y + radius},
+		{r.Min.X + radius, r.Max.Y - radius},
+		{r.Max.X - radius, r.Max.Y - radius},
+	} {
+		c.fillDisc(float64(p.X), float64(p.Y), float64(radius), col)
+	}
+}
+
+// DrawArc draws the part of the circle around x, y from the angle start to
+// end, in degrees clockwise from 3 o'clock. The stroke lies inside radius.
+func (c Canvas) DrawArc(x int, y int, radius int, start float64, end float64, s Stroke) {
+	outer := float64(radius)
+	inner := outer - float64(s.width())
+	full := end-start >= 360
+	start = math.Mod(math.Mod(start, 360)+360, 360)
+	sweep := end - start
+	if !full {
+		sweep = math.Mod(math.Mod(end-start, 360)+360, 360)
+		if sweep == 0 {
+			return
+		}
+	}
+	for py := y - radius; py <= y+radius; py++ {
+		for px := x - radius; px <= x+radius; px++ {
+			// Measure from pixel centers around the point between the
+			// four pixels at x, y.
+			dx, dy := float64(px-x)+0.5, float64(py-y)+0.5
+			d := math.Hypot(dx, dy)
+			if d > outer || d <= inner {
+				continue
+			}
+			angle := math.Mod(math.Atan2(dy, dx)*180/math.Pi-start+720, 360)
+			if !full && angle > sweep {
+				continue
+			}
+			if s.on(angle * math.Pi / 180 * outer) {
+				c.dst.Set(px, py, s.Color.ToColor())
+			}
+		}
+	}
+}
+
+// DrawRing draws the circle around x, y with the stroke inside radius.
+func (c Canvas) DrawRing(x int, y int, radius int, s Stroke) {
+	c.DrawArc(x, y, radius, 0, 360, s)
+}
+
+// FillPolygon fills the polygon through points with col, by the even-odd
+// rule at pixel centers.
+func (c Canvas) FillPolygon(points []image.Point, col Color) {
+	if len(points) < 3 {
+		return
+	}
+	bounds := image.Rectangle{Min: points[0], Max: points[0]}
+	for _, p := range points {
+		bounds = bounds.Union(image.Rectangle{Min: p, Max: p.Add(image.Pt(1, 1))})
+	}
+	bounds = bounds.Intersect(c.dst.Bounds())
+	var xs []float64
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		cy := float64(y) + 0.5
+		xs = xs[:0]
+		for i, p := range points {
+			q := points[(i+1)%len(points)]
+			y0, y1 := float64(p.Y), float64(q.Y)
+			if (y0 <= cy) == (y1 <= cy) {
+				continue
+			}
+			xs = append(xs, float64(p.X)+(cy-y0)/(y1-y0)*float64(q.X-p.X))
+		}
+		sort.Float64s(xs)
+		for i := 0; i+1 < len(xs); i += 2 {
+			from := int(math.Ceil(xs[i] - 0.5))
+			to := int(math.Ceil(xs[i+1] - 0.5))
+			c.FillRect(image.Rect(from, y, to, y+1), col)
+		}
+	}
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,153 @@
+package canvas
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func shapeCanvas() (Canvas, *image.RGBA) {
+	img := image.NewRGBA(image.Rect(0, 0, 64, 64))
+	c := NewCanvas(img)
+	c.FillRect(img.Rect, White)
+	return c, img
+}
+
+// drawn returns how many pixels of r are not white.
+func drawn(img *image.RGBA, r image.Rectangle) int {
+	n := 0
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			if img.At(x, y) != (color.RGBA{0xff, 0xff, 0xff, 0xff}) {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+func isSet(img *image.RGBA, x int, y int) bool {
+	return drawn(img, image.Rect(x, y, x+1, y+1)) == 1
+}
+
+func TestDrawLine(t *testing.T) {
+	c, img := shapeCanvas()
+	c.DrawLine(2, 5, 21, 5, Stroke{Color: Black})
+	if n := drawn(img, img.Rect); n != 20 {
+		t.Errorf("horizontal line drew %d pixels, want 20", n)
+	}
+
+	c, img = shapeCanvas()
+	c.DrawLine(10, 2, 10, 21, Stroke{Color: Red, Width: 3})
+	if n := drawn(img, image.Rect(9, 2, 12, 22)); n != 60 {
+		t.Errorf("vertical line drew %d pixels in its band, want 60", n)
+	}
+	if isSet(img, 7, 10) || isSet(img, 13, 10) {
+		t.Errorf("vertical line is wider than 3")
+	}
+
+	c, img = shapeCanvas()
+	c.DrawLine(0, 0, 20, 10, Stroke{Color: Black})
+	for _, p := range []image.Point{{0, 0}, {10, 5}, {20, 10}} {
+		if !isSet(img, p.X, p.Y) {
+			t.Errorf("angled line misses %v", p)
+		}
+	}
+
+	c, img = shapeCanvas()
+	c.DrawLine(0, 0, 19, 0, Stroke{Color: Black, Dash: Dashed})
+	if n := drawn(img, img.Rect); n != 12 || !isSet(img, 5, 0) || isSet(img, 6, 0) || !isSet(img, 10, 0) {
+		t.Errorf("dashed line drew %d pixels, want two dashes of 6", n)
+	}
+	c, img = shapeCanvas()
+	c.DrawLine(0, 0, 0, 29, Stroke{Color: Black, Dash: Dotted})
+	if n := drawn(img, img.Rect); n != 10 {
+		t.Errorf("dotted line drew %d pixels, want 10", n)
+	}
+}
+
+func TestDrawRect(t *testing.T) {
+	c, img := shapeCanvas()
+	r := image.Rect(10, 10, 30, 20)
+	c.DrawRect(r, 0, Stroke{Color: Black, Width: 2})
+	if n := drawn(img, img.Rect); n != 2*20*2+2*6*2 {
+		t.Errorf("outline drew %d pixels, want 104", n)
+	}
+	if drawn(img, r.Inset(2)) != 0 || !isSet(img, 10, 10) || !isSet(img, 29, 19) {
+		t.Errorf("outline is not on the inside edge of %v", r)
+	}
+
+	c, img = shapeCanvas()
+	c.DrawRect(image.Rect(10, 10, 40, 40), 8, Stroke{Color: Red, Width: 2})
+	if isSet(img, 10, 10) || isSet(img, 39, 39) || !isSet(img, 25, 10) || !isSet(img, 10, 25) {
+		t.Errorf("rounded outline has square corners or misses its sides")
+	}
+	// The rounded corner meets the straight sides.
+	if !isSet(img, 12, 12) {
+		t.Errorf("rounded corner misses the diagonal")
+	}
+
+	c, img = shapeCanvas()
+	c.FillRoundedRect(image.Rect(10, 10, 40, 30), 6, Black)
+	if isSet(img, 10, 10) || !isSet(img, 25, 20) || !isSet(img, 10, 20) || !isSet(img, 25, 10) {
+		t.Errorf("rounded fill is wrong at its corners or sides")
+	}
+	if n := drawn(img, img.Rect); n >= 30*20 || n < 30*20-4*6*6 {
+		t.Errorf("rounded fill drew %d pixels", n)
+	}
+}
+
+func TestDrawArc(t *testing.T) {
+	c, img := shapeCanvas()
+	c.DrawRing(32, 32, 10, Stroke{Color: Red, Width: 3})
+	if !isSet(img, 32+8, 32) || !isSet(img, 32-9, 32) || !isSet(img, 32, 32-10) {
+		t.Errorf("ring misses its stroke")
+	}
+	if isSet(img, 32, 32) || isSet(img, 32+10, 32) || isSet(img, 32+5, 32) {
+		t.Errorf("ring is drawn off its stroke")
+	}
+
+	// Angles run clockwise from 3 o'clock, so 0 to 90 is the lower right.
+	c, img = shapeCanvas()
+	c.DrawArc(32, 32, 10, 0, 90, Stroke{Color: Black, Width: 2})
+	if drawn(img, image.Rect(32, 32, 43, 43)) == 0 || drawn(img, image.Rect(20, 20, 44, 32)) != 0 || drawn(img, image.Rect(20, 32, 32, 44)) != 0 {
+		t.Errorf("arc is not only in the lower right")
+	}
+	c, img = shapeCanvas()
+	c.DrawArc(32, 32, 10, 270, 90, Stroke{Color: Black})
+	if drawn(img, image.Rect(32, 20, 44, 44)) == 0 || drawn(img, image.Rect(20, 20, 32, 44)) != 0 {
+		t.Errorf("arc across 0 is not only on the right")
+	}
+
+	c, img = shapeCanvas()
+	c.DrawRing(32, 32, 20, Stroke{Color: Black, Dash: Dashed})
+	full, _ := shapeCanvas()
+	full.DrawRing(32, 32, 20, Stroke{Color: Black})
+	if n, all := drawn(img, img.Rect), drawn(full.dst.(*image.RGBA), img.Rect); n == 0 || n >= all*3/4 {
+		t.Errorf("dashed ring drew %d of %d pixels", n, all)
+	}
+}
+
+func TestFillPolygon(t *testing.T) {
+	c, img := shapeCanvas()
+	c.FillPolygon([]image.Point{{2, 2}, {6, 2}, {6, 6}, {2, 6}}, Black)
+	if n := drawn(img, img.Rect); n != 16 || drawn(img, image.Rect(2, 2, 6, 6)) != 16 {
+		t.Errorf("square drew %d pixels, want its 16", n)
+	}
+
+	c, img = shapeCanvas()
+	c.FillPolygon([]image.Point{{0, 0}, {20, 0}, {0, 20}}, Red)
+	if n := drawn(img, img.Rect); n < 190 || n > 210 {
+		t.Errorf("triangle drew %d pixels, want about 200", n)
+	}
+	if !isSet(img, 1, 1) || isSet(img, 15, 15) {
+		t.Errorf("triangle is on the wrong side of its diagonal")
+	}
+
+	// A concave arrow leaves its notch empty.
+	c, img = shapeCanvas()
+	c.FillPolygon([]image.Point{{0, 0}, {20, 10}, {0, 20}, {8, 10}}, Black)
+	if isSet(img, 3, 10) || !isSet(img, 12, 10) {
+		t.Errorf("concave polygon fills its notch")
+	}
+}
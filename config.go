@@ -0,0 +1,332 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"wallcalendar/canvas"
+	"wallcalendar/holidays"
+	"wallcalendar/locale"
+)
+
+// Config is read from a JSON file, config.json by default.
+type Config struct {
+	Calendars  []CalendarConfig `json:"calendars"`
+	GoogleAuth GoogleAuthConfig `json:"google_auth"`
+	// StateDir holds the sync state of Google calendars, by default the
+	// working directory.
+	StateDir string `json:"state_dir,omitempty"`
+	// Rules hide or rewrite events, applied in order.
+	Rules   []RuleConfig  `json:"rules,omitempty"`
+	Display DisplayConfig `json:"display"`
+	Fonts   FontsConfig   `json:"fonts,omitempty"`
+	// View is four_weeks (the default), week, two_weeks, month or agenda.
+	View string `json:"view,omitempty"`
+
+	// TimeZone is the IANA name of the zone the calendar is shown in, by
+	// default the system's zone.
+	TimeZone string `json:"timezone,omitempty"`
+	// SecondaryTimeZone, if set, adds the start time in that zone to timed
+	// events, e.g. "3pm (9pm CET)".
+	SecondaryTimeZone string `json:"secondary_timezone,omitempty"`
+}
+
+// DisplayConfig holds the optional parts of how events are drawn.
+type DisplayConfig struct {
+	// ShowLocation adds the event's short location under its title.
+	ShowLocation bool `json:"show_location,omitempty"`
+	// WeekStart is the English name of the first day of the week, e.g.
+	// "monday". Weeks start on Sunday by default.
+	WeekStart string `json:"week_start,omitempty"`
+	// WeekNumbers adds a narrow column with the ISO 8601 week number of
+	// every week row.
+	WeekNumbers bool `json:"week_numbers,omitempty"`
+	// Locale is the language of month and weekday names and dates: en (the
+	// default), de, fr, es or nl.
+	Locale string `json:"locale,omitempty"`
+	// Clock is "12h" or "24h" to override the time format of the locale.
+	Clock string `json:"clock,omitempty"`
+	// MultiDayBars draws events lasting several days as one bar across
+	// their days, with the title aligned "left" or "center" in it. By
+	// default they are drawn as lines.
+	MultiDayBars string `json:"multi_day_bars,omitempty"`
+	// TimeLabel is how the time of timed events is shown: "start" (the
+	// default) for "3pm", "range" for "3–5pm" or "duration" for "3pm 2h".
+	TimeLabel string `json:"time_label,omitempty"`
+	// ShowUntil adds the last day to events lasting several days that began
+	// before the first day shown or end after the last, e.g. "until Mon
+	// 12/2".
+	ShowUntil bool `json:"show_until,omitempty"`
+	// PastDays de-emphasizes the days of the current week before today:
+	// "hatch" or "dots" draws a pattern over them, "hide" leaves out their
+	// events and "compress" also narrows their columns if the week is the
+	// first row. Their events never make a row taller.
+	PastDays string `json:"past_days,omitempty"`
+	// Holidays is the region whose public holidays are shown next to the
+	// date, "us" for the US federal holidays or "de" for Germany.
+	Holidays string `json:"holidays,omitempty"`
+	// MaxLines limits how many lines the text of events may take.
+	MaxLines MaxLines `json:"max_lines,omitempty"`
+}
+
+// MaxLines is the most lines the text of timed, all-day and multi-day events
+// may take, 0 for any number. Longer text ends in "…".
+type MaxLines struct {
+	Events   int `json:"events,omitempty"`
+	AllDay   int `json:"all_day,omitempty"`
+	MultiDay int `json:"multi_day,omitempty"`
+}
+
+// hidesPast reports whether the events of past days are left out.
+func (d DisplayConfig) hidesPast() bool {
+	return d.PastDays == "hide" || d.PastDays == "compress"
+}
+
+// FontsConfig are the TrueType files event text is drawn with. A glyph
+// missing from Text is taken from Symbol, and then from Emoji, which must be
+// a monochrome font.
+type FontsConfig struct {
+	// Text is the main font, fonts/UnifontExMono.ttf by default.
+	Text   string `json:"text,omitempty"`
+	Symbol string `json:"symbol,omitempty"`
+	Emoji  string `json:"emoji,omitempty"`
+}
+
+// Files returns the font files in fallback order.
+func (f FontsConfig) Files() []string {
+	files := []string{f.Text}
+	if f.Text == "" {
+		files[0] = "fonts/UnifontExMono.ttf"
+	}
+	for _, file := range []string{f.Symbol, f.Emoji} {
+		if file != "" {
+			files = append(files, file)
+		}
+	}
+	return files
+}
+
+// FirstWeekday returns the day weeks start on.
+func (d DisplayConfig) FirstWeekday() time.Weekday {
+	day, _ := parseWeekday(d.WeekStart)
+	return day
+}
+
+func parseWeekday(s string) (time.Weekday, error) {
+	if s == "" {
+		return time.Sunday, nil
+	}
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		if strings.EqualFold(s, day.String()) {
+			return day, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown week_start %q", s)
+}
+
+// GoogleAuthConfig selects the credentials used for Google calendars.
+type GoogleAuthConfig struct {
+	// Type is "oauth" (the default) to use credentials.json and the
+	// token.json written by the auth command, or "service_account" to use
+	// the key in KeyFile. Calendars must be shared with the service account.
+	Type    string `json:"type,omitempty"`
+	KeyFile string `json:"key_file,omitempty"`
+}
+
+// CalendarConfig describes one calendar shown on the wall and how its events
+// are drawn. Exactly one of GoogleID, ICS and CalDAV must be set.
+type CalendarConfig struct {
+	Name string `json:"name"`
+	// GoogleID is the ID of a Google calendar, e.g. "primary".
+	GoogleID string `json:"google_id,omitempty"`
+	// ICS is the path or URL of an iCalendar file.
+	ICS string `json:"ics,omitempty"`
+	// CalDAV is the URL of a CalDAV calendar collection, accessed with
+	// Username and Password if set.
+	CalDAV   string `json:"caldav,omitempty"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+
+	// Color of the event text, "black" (the default) or "red".
+	Color string `json:"color,omitempty"`
+	// Prefix is drawn before every event title, e.g. initials.
+	Prefix string `json:"prefix,omitempty"`
+	// Marker is a glyph drawn before every event title, e.g. "●".
+	Marker string `json:"marker,omitempty"`
+}
+
+func defaultConfig() Config {
+	return Config{
+		Calendars: []CalendarConfig{
+			{
+				Name:     "family",
+				GoogleID: "family01175849838019336469@group.calendar.google.com",
+			},
+		},
+	}
+}
+
+// LoadConfig reads the config at path, falling back to the defaults when the
+// file does not exist.
+func LoadConfig(path string) (Config, error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return defaultConfig(), nil
+	}
+	if err != nil {
+		return Config{}, err
+	}
+	var config Config
+	if err := json.Unmarshal(b, &config); err != nil {
+		return Config{}, fmt.Errorf("unable to parse %s: %w", path, err)
+	}
+	if _, _, err := config.Locations(); err != nil {
+		return Config{}, err
+	}
+	if _, err := config.Locale(); err != nil {
+		return Config{}, err
+	}
+	switch config.Display.MultiDayBars {
+	case "", "left", "center":
+	default:
+		return Config{}, fmt.Errorf("unknown multi_day_bars %q, want left or center", config.Display.MultiDayBars)
+	}
+	switch config.Display.TimeLabel {
+	case "", "start", "range", "duration":
+	default:
+		return Config{}, fmt.Errorf("unknown time_label %q, want start, range or duration", config.Display.TimeLabel)
+	}
+	switch config.Display.PastDays {
+	case "", "hatch", "dots", "hide", "compress":
+	default:
+		return Config{}, fmt.Errorf("unknown past_days %q, want hatch, dots, hide or compress", config.Display.PastDays)
+	}
+	if m := config.Display.MaxLines; m.Events < 0 || m.AllDay < 0 || m.MultiDay < 0 {
+		return Config{}, fmt.Errorf("max_lines must not be negative")
+	}
+	if _, err := holidays.Get(config.Display.Holidays); err != nil {
+		return Config{}, err
+	}
+	if _, err := parseWeekday(config.Display.WeekStart); err != nil {
+		return Config{}, err
+	}
+	if _, err := ParseView(config.View); err != nil {
+		return Config{}, err
+	}
+	if _, err := CompileRules(config.Rules); err != nil {
+		return Config{}, err
+	}
+	switch config.GoogleAuth.Type {
+	case "", "oauth":
+	case "service_account":
+		if config.GoogleAuth.KeyFile == "" {
+			return Config{}, fmt.Errorf("google_auth of type service_account needs a key_file")
+		}
+	default:
+		return Config{}, fmt.Errorf("unknown google_auth type %q", config.GoogleAuth.Type)
+	}
+	if len(config.Calendars) == 0 {
+		return Config{}, fmt.Errorf("%s lists no calendars", path)
+	}
+	for _, cal := range config.Calendars {
+		sources := 0
+		for _, s := range []string{cal.GoogleID, cal.ICS, cal.CalDAV} {
+			if s != "" {
+				sources++
+			}
+		}
+		if sources != 1 {
+			return Config{}, fmt.Errorf("calendar %q needs exactly one of google_id, ics and caldav", cal.Name)
+		}
+		if _, err := parseColor(cal.Color); err != nil {
+			return Config{}, fmt.Errorf("calendar %q: %w", cal.Name, err)
+		}
+	}
+	return config, nil
+}
+
+// Locations returns the display time zone and the secondary zone, which is
+// nil if none is configured.
+func (c Config) Locations() (*time.Location, *time.Location, error) {
+	tz := time.Local
+	if c.TimeZone != "" {
+		var err error
+		tz, err = time.LoadLocation(c.TimeZone)
+		if err != nil {
+			return nil, nil, fmt.Errorf("timezone: %w", err)
+		}
+	}
+	if c.SecondaryTimeZone == "" {
+		return tz, nil, nil
+	}
+	secondary, err := time.LoadLocation(c.SecondaryTimeZone)
+	if err != nil {
+		return nil, nil, fmt.Errorf("secondary_timezone: %w", err)
+	}
+	return tz, secondary, nil
+}
+
+// Locale returns the locale the calendar is shown in.
+func (c Config) Locale() (locale.Locale, error) {
+	return locale.Get(c.Display.Locale, c.Display.Clock)
+}
+
+func parseColor(s string) (canvas.Color, error) {
+	switch s {
+	case "", "black":
+		return canvas.Black, nil
+	case "red":
+		return canvas.Red, nil
+	}
+	return 0, fmt.Errorf("unknown color %q", s)
+}
+
+// Source returns the EventSource for the calendar, using auth for Google
+// calendars and keeping their sync state in stateDir.
+func (c CalendarConfig) Source(auth GoogleAuthConfig, stateDir string) EventSource {
+	if c.ICS != "" {
+		return ICalSource{Location: c.ICS}
+	}
+	if c.CalDAV != "" {
+		return CalDAVSource{URL: c.CalDAV, Username: c.Username, Password: c.Password}
+	}
+	return GoogleSource{
+		CalendarID:    c.GoogleID,
+		Auth:          auth,
+		SyncStatePath: filepath.Join(stateDir, "sync-"+url.PathEscape(c.GoogleID)+".json"),
+	}
+}
+
+// Style returns how events of the calendar are drawn.
+func (c CalendarConfig) Style() CalendarStyle {
+	color, _ := parseColor(c.Color)
+	return CalendarStyle{
+		Color:  color,
+		Prefix: c.Prefix,
+		Marker: c.Marker,
+	}
+}
+
+// Sources returns a MergedSource over all configured calendars.
+func (c Config) Sources() MergedSource {
+	var sources MergedSource
+	for _, cal := range c.Calendars {
+		sources = append(sources, CalendarSource{Name: cal.Name, Source: cal.Source(c.GoogleAuth, c.StateDir)})
+	}
+	return sources
+}
+
+// Styles returns the style of every configured calendar by name.
+func (c Config) Styles() map[string]CalendarStyle {
+	styles := make(map[string]CalendarStyle)
+	for _, cal := range c.Calendars {
+		styles[cal.Name] = cal.Style()
+	}
+	return styles
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		json    string
+		wantErr bool
+	}{
+		{"ics", `{"calendars": [{"name": "school", "ics": "school.ics"}]}`, false},
+		{"service account", `{"google_auth": {"type": "service_account", "key_file": "sa.json"}, "calendars": [{"name": "family", "google_id": "family@example.com"}]}`, false},
+		{"service account without key", `{"google_auth": {"type": "service_account"}, "calendars": [{"name": "family", "google_id": "family@example.com"}]}`, true},
+		{"unknown auth", `{"google_auth": {"type": "magic"}, "calendars": [{"name": "family", "google_id": "family@example.com"}]}`, true},
+		{"no calendars", `{"calendars": []}`, true},
+		{"two sources", `{"calendars": [{"name": "school", "ics": "school.ics", "caldav": "https://example.com/"}]}`, true},
+		{"bad timezone", `{"timezone": "Mars/Olympus_Mons", "calendars": [{"name": "school", "ics": "school.ics"}]}`, true},
+		{"bad secondary timezone", `{"secondary_timezone": "CET+", "calendars": [{"name": "school", "ics": "school.ics"}]}`, true},
+		{"month view", `{"view": "month", "calendars": [{"name": "school", "ics": "school.ics"}]}`, false},
+		{"monday weeks", `{"display": {"week_start": "Monday", "week_numbers": true}, "calendars": [{"name": "school", "ics": "school.ics"}]}`, false},
+		{"bad week start", `{"display": {"week_start": "mon"}, "calendars": [{"name": "school", "ics": "school.ics"}]}`, true},
+		{"german", `{"display": {"locale": "de", "clock": "12h"}, "calendars": [{"name": "school", "ics": "school.ics"}]}`, false},
+		{"bad locale", `{"display": {"locale": "tlh"}, "calendars": [{"name": "school", "ics": "school.ics"}]}`, true},
+		{"bad clock", `{"display": {"clock": "24"}, "calendars": [{"name": "school", "ics": "school.ics"}]}`, true},
+		{"bars", `{"display": {"multi_day_bars": "center"}, "calendars": [{"name": "school", "ics": "school.ics"}]}`, false},
+		{"bad bars", `{"display": {"multi_day_bars": "right"}, "calendars": [{"name": "school", "ics": "school.ics"}]}`, true},
+		{"time ranges", `{"display": {"time_label": "range", "show_until": true}, "calendars": [{"name": "school", "ics": "school.ics"}]}`, false},
+		{"past days", `{"display": {"past_days": "compress"}, "calendars": [{"name": "school", "ics": "school.ics"}]}`, false},
+		{"bad past days", `{"display": {"past_days": "blur"}, "calendars": [{"name": "school", "ics": "school.ics"}]}`, true},
+		{"holidays", `{"display": {"holidays": "us"}, "calendars": [{"name": "school", "ics": "school.ics"}]}`, false},
+		{"bad holidays", `{"display": {"holidays": "mars"}, "calendars": [{"name": "school", "ics": "school.ics"}]}`, true},
+		{"max lines", `{"display": {"max_lines": {"events": 2, "all_day": 1, "multi_day": 1}}, "calendars": [{"name": "school", "ics": "school.ics"}]}`, false},
+		{"negative max lines", `{"display": {"max_lines": {"events": -1}}, "calendars": [{"name": "school", "ics": "school.ics"}]}`, true},
+		{"bad time label", `{"display": {"time_label": "end"}, "calendars": [{"name": "school", "ics": "school.ics"}]}`, true},
+		{"bad view", `{"view": "year", "calendars": [{"name": "school", "ics": "school.ics"}]}`, true},
+		{"bad color", `{"calendars": [{"name": "school", "ics": "school.ics", "color": "green"}]}`, true},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "config.json")
+		os.WriteFile(path, []byte(tt.json), 0600)
+		_, err := LoadConfig(path)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: LoadConfig() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+
+	config, err := LoadConfig(filepath.Join(t.TempDir(), "missing.json"))
+	if err != nil || len(config.Calendars) != 1 || config.TimeZone != "" {
+		t.Errorf("LoadConfig(missing) = %+v, %v, want the default calendar in the system zone", config, err)
+	}
+}
+
+func TestConfigSources(t *testing.T) {
+	config := Config{
+		GoogleAuth: GoogleAuthConfig{Type: "service_account", KeyFile: "sa.json"},
+		Calendars: []CalendarConfig{
+			{Name: "family", GoogleID: "family@example.com"},
+			{Name: "school", ICS: "school.ics"},
+		},
+	}
+	sources := config.Sources()
+	google, ok := sources[0].Source.(GoogleSource)
+	if !ok || google.Auth != config.GoogleAuth || sources[0].Name != "family" {
+		t.Errorf("sources[0] = %+v, want a GoogleSource with the service account", sources[0])
+	}
+	if _, ok := sources[1].Source.(ICalSource); !ok {
+		t.Errorf("sources[1] = %+v, want an ICalSource", sources[1])
+	}
+}
+
+func TestLocations(t *testing.T) {
+	tz, secondary, err := Config{TimeZone: "Europe/Berlin", SecondaryTimeZone: "America/Chicago"}.Locations()
+	if err != nil || tz.String() != "Europe/Berlin" || secondary.String() != "America/Chicago" {
+		t.Errorf("Locations() = %v, %v, %v", tz, secondary, err)
+	}
+	tz, secondary, err = Config{}.Locations()
+	if err != nil || tz != time.Local || secondary != nil {
+		t.Errorf("Locations() = %v, %v, %v, want the system zone", tz, secondary, err)
+	}
+}
+
+func TestFontFiles(t *testing.T) {
+	if got := (FontsConfig{}).Files(); len(got) != 1 || got[0] != "fonts/UnifontExMono.ttf" {
+		t.Errorf("Files() = %q, want the default text font", got)
+	}
+	got := FontsConfig{Text: "text.ttf", Emoji: "emoji.ttf"}.Files()
+	if len(got) != 2 || got[0] != "text.ttf" || got[1] != "emoji.ttf" {
+		t.Errorf("Files() = %q, want text.ttf then emoji.ttf", got)
+	}
+}
@@ -2,26 +2,63 @@ package main
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
-	"net/http"
-	"os"
+	"sort"
+	"strings"
 	"time"
+	"wallcalendar/slots"
 
-	"golang.org/x/oauth2"
-	"golang.org/x/oauth2/google"
 	"google.golang.org/api/calendar/v3"
-	"google.golang.org/api/option"
 )
 
+// Attendee is the organizer or a guest of an event.
+type Attendee struct {
+	Email string
+	Name  string
+	// Response is needsAction, declined, tentative or accepted.
+	Response string
+	Self     bool
+}
+
 type Event struct {
 	ID            string
+	Calendar      string
 	Summary       string
+	Location      string
+	Description   string
 	StartTime     time.Time
 	EndTime       time.Time
 	IsAllDayEvent bool
-	Slot          int
+	// Status is confirmed, tentative or cancelled, and Response is the
+	// calendar owner's response if the event is an invitation.
+	Status   string
+	Response string
+	// Transparency is opaque if the event blocks time and transparent if
+	// it does not.
+	Transparency string
+	// ColorID is the Google Calendar event color, "1" to "11", or "" for
+	// the calendar's color.
+	ColorID          string
+	Organizer        Attendee
+	Attendees        []Attendee
+	RecurringEventID string
+	HTMLLink         string
+	// Highlight draws the event in red.
+	Highlight bool
+
+	// weekSlots is the slot of the event in each week row of the view.
+	weekSlots []int
+}
+
+// SlotInWeek returns the slot of the event in the given week row, or -1 if
+// the event is not shown in that row.
+func (e Event) SlotInWeek(week int) int {
+	if week < 0 || week >= len(e.weekSlots) {
+		return -1
+	}
+	return e.weekSlots[week]
 }
 
 func getTime(dateTime *calendar.EventDateTime, tz *time.Location, isEnd bool) (time.Time, error) {
@@ -52,13 +89,43 @@ func NewEvent(e *calendar.Event, tz *time.Location) (Event, error) {
 	if err != nil {
 		return Event{}, err
 	}
+	response := ""
+	var attendees []Attendee
+	for _, a := range e.Attendees {
+		if a.Self {
+			response = a.ResponseStatus
+		}
+		attendees = append(attendees, Attendee{
+			Email:    a.Email,
+			Name:     a.DisplayName,
+			Response: a.ResponseStatus,
+			Self:     a.Self,
+		})
+	}
+	var organizer Attendee
+	if e.Organizer != nil {
+		organizer = Attendee{Email: e.Organizer.Email, Name: e.Organizer.DisplayName, Self: e.Organizer.Self}
+	}
+	transparency := e.Transparency
+	if transparency == "" {
+		transparency = "opaque"
+	}
 	return Event{
-		ID:            e.Id,
-		Summary:       e.Summary,
-		StartTime:     start,
-		EndTime:       end,
-		IsAllDayEvent: e.Start.Date != "",
-		Slot:          -1,
+		ID:               e.Id,
+		Summary:          e.Summary,
+		Location:         e.Location,
+		Description:      e.Description,
+		StartTime:        start,
+		EndTime:          end,
+		IsAllDayEvent:    e.Start.Date != "",
+		Status:           e.Status,
+		Response:         response,
+		Transparency:     transparency,
+		ColorID:          e.ColorId,
+		Organizer:        organizer,
+		Attendees:        attendees,
+		RecurringEventID: e.RecurringEventId,
+		HTMLLink:         e.HtmlLink,
 	}, nil
 }
 
@@ -76,202 +143,234 @@ func (e Event) EndsOnDate(date time.Time, tz *time.Location) bool {
 	return isSameDay(e.EndTime, date, tz)
 }
 
-func (e Event) StartTimeShort(tz *time.Location) string {
-	fmt := "3:04pm "
-	if e.StartTime.Minute() == 0 {
-		fmt = "3pm "
-	}
-	return e.StartTime.In(tz).Format(fmt)
+// MultiDay reports whether the event ends on a later day than it starts.
+func (e Event) MultiDay(tz *time.Location) bool {
+	return daysBetween(e.StartTime, e.EndTime, tz) > 0
 }
 
-func FetchEvents(today time.Time, calendarId string, tz *time.Location) (map[time.Time][]*Event, time.Time, time.Time) {
-	ctx := context.Background()
-	b, err := os.ReadFile("credentials.json")
-	if err != nil {
-		log.Fatalf("Unable to read client secret file: %v", err)
-	}
-
-	// If modifying these scopes, delete your previously saved token.json.
-	config, err := google.ConfigFromJSON(b, calendar.CalendarReadonlyScope)
-	if err != nil {
-		log.Fatalf("Unable to parse client secret file to config: %v", err)
-	}
-	client := getClient(config)
-
-	srv, err := calendar.NewService(ctx, option.WithHTTPClient(client))
-	if err != nil {
-		log.Fatalf("Unable to retrieve Calendar client: %v", err)
-	}
+// ShortLocation returns the location up to the first comma, which is usually
+// the name of the place without its address.
+func (e Event) ShortLocation() string {
+	loc, _, _ := strings.Cut(e.Location, ",")
+	return strings.TrimSpace(loc)
+}
 
-	start := startOfDayOfWeek(today, tz)
-	end := start.AddDate(0, 0, numWeeks*7)
-	lastday := end.AddDate(0, 0, -1)
+// EventSource provides the events of a calendar.
+type EventSource interface {
+	// Events returns the events overlapping [start, end), ordered by start
+	// time.
+	Events(ctx context.Context, start time.Time, end time.Time, tz *time.Location) ([]*Event, error)
+}
 
-	dateMap := make(map[time.Time][]*Event)
-	var allEvents []*Event
+// CalendarSource tags every event of Source with the calendar Name.
+type CalendarSource struct {
+	Name   string
+	Source EventSource
+}
 
-	events, err := srv.Events.List(calendarId).ShowDeleted(false).
-		SingleEvents(true).TimeMin(start.Format(time.RFC3339)).TimeMax(end.Format(time.RFC3339)).OrderBy("startTime").Do()
-	if err != nil {
-		log.Fatalf("Unable to retrieve next ten of the user's events: %v", err)
+// MergedSource merges the events of several calendars into one source.
+type MergedSource []CalendarSource
+
+// Events returns the merged events of the calendars. If only some of them
+// fail, it returns the events of the others with SourceErrors; if all do, it
+// returns their errors joined.
+func (m MergedSource) Events(ctx context.Context, start time.Time, end time.Time, tz *time.Location) ([]*Event, error) {
+	var events []*Event
+	failed := make(SourceErrors)
+	for _, cal := range m {
+		calEvents, err := cal.Source.Events(ctx, start, end, tz)
+		if err != nil {
+			failed[cal.Name] = err
+			continue
+		}
+		for _, e := range calEvents {
+			e.Calendar = cal.Name
+		}
+		events = append(events, calEvents...)
 	}
-
-	if len(events.Items) == 0 {
-		fmt.Println("No upcoming events found.")
-	} else {
-		for _, item := range events.Items {
-			e, _ := NewEvent(item, tz)
-			ePtr := &e
-			allEvents = append(allEvents, ePtr)
-
-			roundedDate := midnight(e.StartTime, tz)
-			roundedEndDate := midnight(e.EndTime, tz)
-			dateMap[roundedDate] = append(dateMap[roundedDate], ePtr)
-			for roundedDate = roundedDate.Add(24 * time.Hour); roundedDate.Compare(roundedEndDate) <= 0; roundedDate = roundedDate.Add(24 * time.Hour) {
-				dateMap[roundedDate] = append(dateMap[roundedDate], ePtr)
-			}
+	if len(failed) > 0 && len(failed) == len(m) {
+		var errs []error
+		for _, name := range failed.Calendars() {
+			errs = append(errs, fmt.Errorf("calendar %s: %w", name, failed[name]))
 		}
+		return nil, errors.Join(errs...)
 	}
+	sortEvents(events)
+	if len(failed) > 0 {
+		return events, failed
+	}
+	return events, nil
+}
 
-	// Assign slots
-	// We need to iterate day by day.
-	// For each day, we look at the events on that day.
-	// If an event already has a slot assigned (from a previous day), we respect it.
-	// If not, we find the first available slot that is free for the duration of the event (or at least for the days we are rendering).
-
-	// To do this efficiently, we can keep track of slot usage per day.
-	// map[time.Time]map[int]bool -> day -> slotIndex -> occupied
+// SourceErrors holds the errors of the calendars of a MergedSource that
+// failed while others did not, by calendar name.
+type SourceErrors map[string]error
 
-	slotUsage := make(map[time.Time]map[int]bool)
+// Calendars returns the names of the failed calendars in order.
+func (e SourceErrors) Calendars() []string {
+	var names []string
+	for name := range e {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
 
-	// Initialize slotUsage for all days in range
-	curr := start
-	for !curr.After(lastday) {
-		slotUsage[curr] = make(map[int]bool)
-		curr = curr.AddDate(0, 0, 1)
+func (e SourceErrors) Error() string {
+	var msgs []string
+	for _, name := range e.Calendars() {
+		msgs = append(msgs, fmt.Sprintf("calendar %s: %v", name, e[name]))
 	}
+	return strings.Join(msgs, "; ")
+}
 
-	// Iterate through all events. Since they are sorted by start time (from API), we process earlier events first.
-	// However, the API sort might not be strictly what we want for multi-day vs single day.
-	// Usually, longer events starting on the same day should be processed first or standard layout algorithms apply.
-	// For simplicity, we process in the order returned.
-
-	for _, e := range allEvents {
-		// Find the days this event covers within our view
-		var days []time.Time
-		s := midnight(e.StartTime, tz)
-		if s.Before(start) {
-			s = start
-		}
-		f := midnight(e.EndTime, tz)
-		if f.After(lastday) {
-			f = lastday
-		}
+func sortEvents(events []*Event) {
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].StartTime.Before(events[j].StartTime)
+	})
+}
 
-		// Collect days
-		iter := s
-		for !iter.After(f) {
-			days = append(days, iter)
-			iter = iter.AddDate(0, 0, 1)
-		}
+// EventWindow holds the events of the days shown on the calendar.
+type EventWindow struct {
+	Dates   map[time.Time][]*Event
+	Start   time.Time
+	LastDay time.Time
+	// Weeks is the number of week rows from Start to LastDay.
+	Weeks int
+	// SyncedAt is when the events were fetched if they had to be read from
+	// the cache, and zero if they are fresh.
+	SyncedAt time.Time
+	// StaleCalendars names the calendars read from the cache if the others
+	// are fresh.
+	StaleCalendars []string
+}
 
-		if len(days) == 0 {
-			continue
-		}
+// Stale reports whether the events come from the cache.
+func (w EventWindow) Stale() bool {
+	return !w.SyncedAt.IsZero()
+}
 
-		// Find a slot that is free for ALL 'days'
-		slot := 0
-		for {
-			isFree := true
-			for _, day := range days {
-				if used, ok := slotUsage[day]; ok {
-					if used[slot] {
-						isFree = false
-						break
-					}
+// fetchTimeout bounds how long FetchEvents waits for the source before it
+// falls back to the cache.
+var fetchTimeout = time.Minute
+
+// FetchEvents reads the events of the days view shows around today, with
+// weeks starting on weekStart, from source, applies rules and assigns their
+// slots. Successful fetches are saved to cache, and the cached events are
+// used instead when the fetch fails or takes longer than fetchTimeout. If only
+// some calendars of a MergedSource fail, only theirs are.
+func FetchEvents(today time.Time, view View, weekStart time.Weekday, source EventSource, cache EventCache, rules []Rule, tz *time.Location) (EventWindow, error) {
+	start, weeks := view.Window(today, weekStart, tz)
+	end := start.AddDate(0, 0, weeks*7)
+	lastday := end.AddDate(0, 0, -1)
+
+	var syncedAt time.Time
+	var staleCalendars []string
+	ctx, cancel := context.WithTimeout(context.Background(), fetchTimeout)
+	defer cancel()
+	allEvents, err := source.Events(ctx, start, end, tz)
+	var failed SourceErrors
+	if err == nil {
+		if err := cache.Save(time.Now(), allEvents); err != nil {
+			log.Printf("Unable to cache events: %v", err)
+		}
+	} else if errors.As(err, &failed) {
+		cached, cachedAt, cacheErr := cache.Load(start, end, tz)
+		if cacheErr != nil {
+			log.Printf("Unable to retrieve events of some calendars, showing the others: %v", err)
+		} else {
+			log.Printf("Unable to retrieve events of some calendars, using their events cached at %v: %v", cachedAt, err)
+			for _, e := range cached {
+				if failed[e.Calendar] != nil {
+					allEvents = append(allEvents, e)
 				}
 			}
-			if isFree {
-				break
+			sortEvents(allEvents)
+			syncedAt, staleCalendars = cachedAt, failed.Calendars()
+			// The cache keeps its time, as the events of the failed
+			// calendars are no newer.
+			if err := cache.Save(cachedAt, allEvents); err != nil {
+				log.Printf("Unable to cache events: %v", err)
 			}
-			slot++
 		}
+	} else {
+		if cache.Path == "" {
+			return EventWindow{}, err
+		}
+		var cacheErr error
+		allEvents, syncedAt, cacheErr = cache.Load(start, end, tz)
+		if cacheErr != nil {
+			return EventWindow{}, fmt.Errorf("%w (and no cached events: %v)", err, cacheErr)
+		}
+		log.Printf("Unable to retrieve events, using events cached at %v: %v", syncedAt, err)
+	}
+	allEvents = ApplyRules(rules, allEvents)
 
-		// Assign slot
-		e.Slot = slot
+	dateMap := make(map[time.Time][]*Event)
 
-		// Mark as used
-		for _, day := range days {
-			if _, ok := slotUsage[day]; ok {
-				slotUsage[day][slot] = true
+	if len(allEvents) == 0 {
+		fmt.Println("No upcoming events found.")
+	} else {
+		for _, e := range allEvents {
+			roundedDate := midnight(e.StartTime, tz)
+			roundedEndDate := midnight(e.EndTime, tz)
+			dateMap[roundedDate] = append(dateMap[roundedDate], e)
+			for roundedDate = roundedDate.AddDate(0, 0, 1); roundedDate.Compare(roundedEndDate) <= 0; roundedDate = roundedDate.AddDate(0, 0, 1) {
+				dateMap[roundedDate] = append(dateMap[roundedDate], e)
 			}
 		}
 	}
 
-	return dateMap, start, lastday
-}
-
-func startOfDayOfWeek(date time.Time, location *time.Location) time.Time {
-	daysSinceSunday := int(date.Weekday())
-	return midnight(date.AddDate(0, 0, -daysSinceSunday), location)
-}
+	// Assign slots, week row by week row.
+	spans := make([]slots.Span, len(allEvents))
+	for i, e := range allEvents {
+		spans[i] = slots.Span{
+			First:  daysBetween(start, e.StartTime, tz),
+			Last:   daysBetween(start, e.EndTime, tz),
+			AllDay: e.IsAllDayEvent,
+			Start:  e.StartTime,
+			Key:    e.Calendar + "/" + e.ID,
+		}
+	}
+	for i, weekSlots := range slots.Assign(spans, weeks) {
+		allEvents[i].weekSlots = weekSlots
+	}
 
-func midnight(t time.Time, tz *time.Location) time.Time {
-	t = t.In(tz)
-	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, tz)
+	return EventWindow{
+		Dates:          dateMap,
+		Start:          start,
+		LastDay:        lastday,
+		Weeks:          weeks,
+		SyncedAt:       syncedAt,
+		StaleCalendars: staleCalendars,
+	}, nil
 }
 
-// Retrieve a token, saves the token, then returns the generated client.
-func getClient(config *oauth2.Config) *http.Client {
-	// The file token.json stores the user's access and refresh tokens, and is
-	// created automatically when the authorization flow completes for the first
-	// time.
-	tokFile := "token.json"
-	tok, err := tokenFromFile(tokFile)
-	if err != nil {
-		tok = getTokenFromWeb(config)
-		saveToken(tokFile, tok)
-	}
-	return config.Client(context.Background(), tok)
+// startOfWeek returns the midnight starting the week of date, for weeks that
+// begin on first.
+func startOfWeek(date time.Time, first time.Weekday, location *time.Location) time.Time {
+	daysSinceFirst := (int(date.In(location).Weekday()) - int(first) + 7) % 7
+	return midnight(date.AddDate(0, 0, -daysSinceFirst), location)
 }
 
-// Request a token from the web, then returns the retrieved token.
-func getTokenFromWeb(config *oauth2.Config) *oauth2.Token {
-	authURL := config.AuthCodeURL("state-token", oauth2.AccessTypeOffline)
-	fmt.Printf("Go to the following link in your browser then type the "+
-		"authorization code: \n%v\n", authURL)
-
-	var authCode string
-	if _, err := fmt.Scan(&authCode); err != nil {
-		log.Fatalf("Unable to read authorization code: %v", err)
-	}
-
-	tok, err := config.Exchange(context.TODO(), authCode)
-	if err != nil {
-		log.Fatalf("Unable to retrieve token from web: %v", err)
-	}
-	return tok
+// isoWeek returns the ISO 8601 week number of the week row starting at start,
+// which is the ISO week of the row's Thursday and so of most of its days.
+func isoWeek(start time.Time) int {
+	thursday := start.AddDate(0, 0, (int(time.Thursday)-int(start.Weekday())+7)%7)
+	_, week := thursday.ISOWeek()
+	return week
 }
 
-// Retrieves a token from a local file.
-func tokenFromFile(file string) (*oauth2.Token, error) {
-	f, err := os.Open(file)
-	if err != nil {
-		return nil, err
-	}
-	defer f.Close()
-	tok := &oauth2.Token{}
-	err = json.NewDecoder(f).Decode(tok)
-	return tok, err
+// daysBetween returns the number of calendar days from the date of a to the
+// date of b in tz.
+func daysBetween(a time.Time, b time.Time, tz *time.Location) int {
+	a, b = a.In(tz), b.In(tz)
+	da := time.Date(a.Year(), a.Month(), a.Day(), 0, 0, 0, 0, time.UTC)
+	db := time.Date(b.Year(), b.Month(), b.Day(), 0, 0, 0, 0, time.UTC)
+	return int(db.Sub(da).Hours() / 24)
 }
 
-// Saves a token to a file path.
-func saveToken(path string, token *oauth2.Token) {
-	fmt.Printf("Saving credential file to: %s\n", path)
-	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
-	if err != nil {
-		log.Fatalf("Unable to cache oauth token: %v", err)
-	}
-	defer f.Close()
-	json.NewEncoder(f).Encode(token)
+func midnight(t time.Time, tz *time.Location) time.Time {
+	t = t.In(tz)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, tz)
 }
@@ -0,0 +1,332 @@
+package main
+
+import (
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+	"wallcalendar/locale"
+
+	"google.golang.org/api/calendar/v3"
+)
+
+func TestFetchEventsFromICal(t *testing.T) {
+	ny, _ := time.LoadLocation("America/New_York")
+	today := time.Date(2024, 11, 21, 0, 0, 0, 0, ny)
+
+	window, err := FetchEvents(today, FourWeekView, time.Sunday, ICalSource{Location: "testdata/school.ics"}, EventCache{}, nil, ny)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dateMap, start, lastday := window.Dates, window.Start, window.LastDay
+	if window.Stale() {
+		t.Errorf("fresh events reported as stale")
+	}
+
+	if want := time.Date(2024, 11, 17, 0, 0, 0, 0, ny); !start.Equal(want) {
+		t.Errorf("start = %v, want %v", start, want)
+	}
+	if want := time.Date(2024, 12, 14, 0, 0, 0, 0, ny); !lastday.Equal(want) {
+		t.Errorf("lastday = %v, want %v", lastday, want)
+	}
+
+	pickups := map[string]bool{}
+	for date, events := range dateMap {
+		for _, e := range events {
+			if e.Summary == "Early pickup" {
+				pickups[date.Format(time.DateOnly)] = true
+				if !e.StartsOnDate(date, ny) || locale.English.Time(e.StartTime.In(ny)) != "12:30pm" {
+					t.Errorf("pickup on %v starts at %v", date, e.StartTime)
+				}
+			}
+		}
+	}
+	for _, d := range []string{"2024-11-20", "2024-12-04", "2024-12-11"} {
+		if !pickups[d] {
+			t.Errorf("missing pickup on %s", d)
+		}
+	}
+	if pickups["2024-11-27"] {
+		t.Errorf("pickup on excluded date 2024-11-27")
+	}
+
+	var breakEvent *Event
+	for _, d := range []int{27, 28, 29} {
+		events := dateMap[time.Date(2024, 11, d, 0, 0, 0, 0, ny)]
+		if len(events) != 1 || events[0].Summary != "Thanksgiving break" {
+			t.Fatalf("events on Nov %d = %v, want the break", d, events)
+		}
+		if breakEvent != nil && breakEvent != events[0] {
+			t.Errorf("break on Nov %d is a different event", d)
+		}
+		breakEvent = events[0]
+	}
+	if !breakEvent.IsAllDayEvent || !breakEvent.EndsOnDate(time.Date(2024, 11, 29, 0, 0, 0, 0, ny), ny) {
+		t.Errorf("break = %+v, want all-day ending Nov 29", breakEvent)
+	}
+	if breakEvent.SlotInWeek(1) != 0 {
+		t.Errorf("break slot = %d, want 0", breakEvent.SlotInWeek(1))
+	}
+	if len(dateMap[time.Date(2024, 11, 30, 0, 0, 0, 0, ny)]) != 0 {
+		t.Errorf("break leaks into Nov 30")
+	}
+}
+
+func TestMergedSource(t *testing.T) {
+	ny, _ := time.LoadLocation("America/New_York")
+	today := time.Date(2024, 11, 21, 0, 0, 0, 0, ny)
+	source := MergedSource{
+		{Name: "school", Source: ICalSource{Location: "testdata/school.ics"}},
+		{Name: "holidays", Source: ICalSource{Location: "testdata/holidays.ics"}},
+	}
+
+	window, err := FetchEvents(today, FourWeekView, time.Sunday, source, EventCache{}, nil, ny)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dateMap := window.Dates
+
+	events := dateMap[time.Date(2024, 11, 28, 0, 0, 0, 0, ny)]
+	if len(events) != 2 {
+		t.Fatalf("got %d events on Thanksgiving, want 2", len(events))
+	}
+	calendars := map[string]string{}
+	for _, e := range events {
+		calendars[e.Summary] = e.Calendar
+	}
+	if calendars["Thanksgiving break"] != "school" || calendars["Thanksgiving"] != "holidays" {
+		t.Errorf("calendars = %v", calendars)
+	}
+	if events[0].SlotInWeek(1) == events[1].SlotInWeek(1) {
+		t.Errorf("both events share slot %d", events[0].SlotInWeek(1))
+	}
+}
+
+func TestFetchEventsFallsBackToCache(t *testing.T) {
+	ny, _ := time.LoadLocation("America/New_York")
+	today := time.Date(2024, 11, 21, 0, 0, 0, 0, ny)
+	cache := EventCache{Path: filepath.Join(t.TempDir(), "cache.json")}
+
+	unreachable := ICalSource{Location: "http://127.0.0.1:1/school.ics"}
+	if _, err := FetchEvents(today, FourWeekView, time.Sunday, unreachable, cache, nil, ny); err == nil {
+		t.Fatalf("fetch from unreachable endpoint without cache succeeded")
+	}
+
+	before := time.Now()
+	if _, err := FetchEvents(today, FourWeekView, time.Sunday, ICalSource{Location: "testdata/school.ics"}, cache, nil, ny); err != nil {
+		t.Fatal(err)
+	}
+
+	// A week later the first week is no longer shown, but the rest of the
+	// cached events are.
+	window, err := FetchEvents(today.AddDate(0, 0, 7), FourWeekView, time.Sunday, unreachable, cache, nil, ny)
+	if err != nil {
+		t.Fatalf("fetch did not fall back to cache: %v", err)
+	}
+	if !window.Stale() || window.SyncedAt.Before(before.Truncate(time.Second)) {
+		t.Errorf("SyncedAt = %v, want stale since %v", window.SyncedAt, before)
+	}
+	if n := len(window.Dates[time.Date(2024, 11, 20, 0, 0, 0, 0, ny)]); n != 0 {
+		t.Errorf("got %d events before the window", n)
+	}
+	events := window.Dates[time.Date(2024, 11, 28, 0, 0, 0, 0, ny)]
+	if len(events) != 1 || events[0].Summary != "Thanksgiving break" || events[0].SlotInWeek(0) != 0 {
+		t.Errorf("events on Nov 28 = %+v, want the break in slot 0", events)
+	}
+	if loc := events[0].StartTime.Location(); loc != ny {
+		t.Errorf("cached event in %v, want %v", loc, ny)
+	}
+}
+
+func TestFetchEventsPartialFailure(t *testing.T) {
+	ny, _ := time.LoadLocation("America/New_York")
+	today := time.Date(2024, 11, 21, 0, 0, 0, 0, ny)
+	cache := EventCache{Path: filepath.Join(t.TempDir(), "cache.json")}
+	source := MergedSource{
+		{Name: "school", Source: ICalSource{Location: "testdata/school.ics"}},
+		{Name: "holidays", Source: ICalSource{Location: "testdata/holidays.ics"}},
+	}
+	before := time.Now()
+	if _, err := FetchEvents(today, FourWeekView, time.Sunday, source, cache, nil, ny); err != nil {
+		t.Fatal(err)
+	}
+
+	// The school calendar now has other events, and the holidays cannot be
+	// fetched.
+	unreachable := ICalSource{Location: "http://127.0.0.1:1/holidays.ics"}
+	source = MergedSource{
+		{Name: "school", Source: ICalSource{Location: "testdata/layout.ics"}},
+		{Name: "holidays", Source: unreachable},
+	}
+	window, err := FetchEvents(today, FourWeekView, time.Sunday, source, cache, nil, ny)
+	if err != nil {
+		t.Fatalf("fetch did not fall back to cache: %v", err)
+	}
+	if !window.Stale() || window.SyncedAt.Before(before.Truncate(time.Second)) || len(window.StaleCalendars) != 1 || window.StaleCalendars[0] != "holidays" {
+		t.Errorf("SyncedAt = %v, StaleCalendars = %v, want the holidays stale since %v", window.SyncedAt, window.StaleCalendars, before)
+	}
+	var summaries []string
+	for _, e := range window.Dates[time.Date(2024, 11, 28, 0, 0, 0, 0, ny)] {
+		summaries = append(summaries, e.Calendar+"/"+e.Summary)
+	}
+	if len(summaries) != 1 || summaries[0] != "holidays/Thanksgiving" {
+		t.Errorf("events on Nov 28 = %v, want only the cached holiday", summaries)
+	}
+	if n := len(window.Dates[time.Date(2024, 12, 4, 0, 0, 0, 0, ny)]); n != 48 {
+		t.Errorf("got %d fresh school events on Dec 4, want 48", n)
+	}
+
+	// Without a cache the other calendars are still shown.
+	window, err = FetchEvents(today, FourWeekView, time.Sunday, source, EventCache{}, nil, ny)
+	if err != nil || window.Stale() || len(window.Dates[time.Date(2024, 12, 4, 0, 0, 0, 0, ny)]) != 48 {
+		t.Errorf("fetch without cache = %v, %v, want the school events", window.StaleCalendars, err)
+	}
+	// Only if every calendar fails does the fetch.
+	source[0].Source = unreachable
+	if _, err := FetchEvents(today, FourWeekView, time.Sunday, source, EventCache{}, nil, ny); err == nil {
+		t.Errorf("fetch of unreachable calendars without cache succeeded")
+	}
+}
+
+func TestFetchEventsTimesOut(t *testing.T) {
+	ny, _ := time.LoadLocation("America/New_York")
+	today := time.Date(2024, 11, 21, 0, 0, 0, 0, ny)
+	cache := EventCache{Path: filepath.Join(t.TempDir(), "cache.json")}
+	if _, err := FetchEvents(today, FourWeekView, time.Sunday, ICalSource{Location: "testdata/school.ics"}, cache, nil, ny); err != nil {
+		t.Fatal(err)
+	}
+
+	// The server accepts connections but never answers.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	defer func(timeout time.Duration) { fetchTimeout = timeout }(fetchTimeout)
+	fetchTimeout = 100 * time.Millisecond
+	silent := ICalSource{Location: "http://" + l.Addr().String() + "/school.ics"}
+	window, err := FetchEvents(today, FourWeekView, time.Sunday, silent, cache, nil, ny)
+	if err != nil {
+		t.Fatalf("fetch did not fall back to cache: %v", err)
+	}
+	if !window.Stale() {
+		t.Errorf("events are not from the cache")
+	}
+}
+
+func TestNewEvent(t *testing.T) {
+	ny, _ := time.LoadLocation("America/New_York")
+	e, err := NewEvent(&calendar.Event{
+		Id:               "abc_20241121T150000Z",
+		Summary:          "Parent-teacher conference",
+		Location:         "Lincoln Elementary, 12 School St, Springfield",
+		Description:      "Room 4",
+		Status:           "confirmed",
+		ColorId:          "11",
+		RecurringEventId: "abc",
+		HtmlLink:         "https://calendar.google.com/event?eid=abc",
+		Start:            &calendar.EventDateTime{DateTime: "2024-11-21T15:00:00Z"},
+		End:              &calendar.EventDateTime{DateTime: "2024-11-21T15:30:00Z"},
+		Organizer:        &calendar.EventOrganizer{Email: "teacher@example.com", DisplayName: "Ms. Lee"},
+		Attendees: []*calendar.EventAttendee{
+			{Email: "teacher@example.com", ResponseStatus: "accepted"},
+			{Email: "alex@example.com", ResponseStatus: "tentative", Self: true},
+		},
+	}, ny)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.ShortLocation() != "Lincoln Elementary" || e.Description != "Room 4" || e.ColorID != "11" {
+		t.Errorf("event = %+v", e)
+	}
+	if e.Transparency != "opaque" || e.Response != "tentative" || e.RecurringEventID != "abc" || e.HTMLLink == "" {
+		t.Errorf("event = %+v", e)
+	}
+	if e.Organizer.Name != "Ms. Lee" || len(e.Attendees) != 2 || !e.Attendees[1].Self {
+		t.Errorf("organizer = %+v, attendees = %+v", e.Organizer, e.Attendees)
+	}
+	if got := locale.English.Time(e.StartTime.In(ny)); got != "10am" {
+		t.Errorf("start time = %q, want 10am", got)
+	}
+}
+
+func TestStartOfWeek(t *testing.T) {
+	ny, _ := time.LoadLocation("America/New_York")
+	tests := []struct {
+		date  time.Time
+		first time.Weekday
+		want  time.Time
+	}{
+		{time.Date(2024, 11, 21, 15, 0, 0, 0, ny), time.Sunday, time.Date(2024, 11, 17, 0, 0, 0, 0, ny)},
+		{time.Date(2024, 11, 21, 15, 0, 0, 0, ny), time.Monday, time.Date(2024, 11, 18, 0, 0, 0, 0, ny)},
+		{time.Date(2024, 11, 17, 0, 0, 0, 0, ny), time.Monday, time.Date(2024, 11, 11, 0, 0, 0, 0, ny)},
+		{time.Date(2024, 11, 18, 0, 0, 0, 0, ny), time.Monday, time.Date(2024, 11, 18, 0, 0, 0, 0, ny)},
+		{time.Date(2024, 11, 21, 15, 0, 0, 0, ny), time.Saturday, time.Date(2024, 11, 16, 0, 0, 0, 0, ny)},
+		// Across the end of daylight saving time.
+		{time.Date(2024, 11, 5, 9, 0, 0, 0, ny), time.Monday, time.Date(2024, 11, 4, 0, 0, 0, 0, ny)},
+		{time.Date(2024, 11, 5, 9, 0, 0, 0, ny), time.Sunday, time.Date(2024, 11, 3, 0, 0, 0, 0, ny)},
+	}
+	for _, tt := range tests {
+		if got := startOfWeek(tt.date, tt.first, ny); !got.Equal(tt.want) {
+			t.Errorf("startOfWeek(%v, %v) = %v, want %v", tt.date, tt.first, got, tt.want)
+		}
+	}
+}
+
+func TestISOWeek(t *testing.T) {
+	tests := []struct {
+		start time.Time
+		want  int
+	}{
+		{time.Date(2024, 11, 18, 0, 0, 0, 0, time.UTC), 47},
+		// A Sunday row is numbered by the Monday to Saturday it shares with
+		// the ISO week.
+		{time.Date(2024, 11, 17, 0, 0, 0, 0, time.UTC), 47},
+		{time.Date(2024, 12, 30, 0, 0, 0, 0, time.UTC), 1},
+		{time.Date(2020, 12, 28, 0, 0, 0, 0, time.UTC), 53},
+		{time.Date(2021, 1, 3, 0, 0, 0, 0, time.UTC), 1},
+	}
+	for _, tt := range tests {
+		if got := isoWeek(tt.start); got != tt.want {
+			t.Errorf("isoWeek(%v) = %d, want %d", tt.start, got, tt.want)
+		}
+	}
+}
+
+func TestFetchEventsMondayWeeks(t *testing.T) {
+	ny, _ := time.LoadLocation("America/New_York")
+	today := time.Date(2024, 11, 21, 0, 0, 0, 0, ny)
+	window, err := FetchEvents(today, FourWeekView, time.Monday, ICalSource{Location: "testdata/school.ics"}, EventCache{}, nil, ny)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2024, 11, 18, 0, 0, 0, 0, ny); !window.Start.Equal(want) {
+		t.Errorf("Start = %v, want %v", window.Start, want)
+	}
+	if want := time.Date(2024, 12, 15, 0, 0, 0, 0, ny); !window.LastDay.Equal(want) {
+		t.Errorf("LastDay = %v, want %v", window.LastDay, want)
+	}
+	// Every event appears in the rows of the days it covers, counted from
+	// Monday.
+	for date, events := range window.Dates {
+		if date.Before(window.Start) || date.After(window.LastDay) {
+			continue
+		}
+		row := daysBetween(window.Start, date, ny) / 7
+		for _, e := range events {
+			if e.SlotInWeek(row) < 0 {
+				t.Errorf("%s on %v has no slot in row %d", e.Summary, date, row)
+			}
+		}
+	}
+}
@@ -8,9 +8,10 @@ require (
 	github.com/tdewolff/canvas v0.0.0-20241017013131-7441cbff7ba9
 	golang.org/x/oauth2 v0.23.0
 	google.golang.org/api v0.203.0
+	rsc.io/qr v0.2.0
 )
 
-require github.com/lovelydeng/uniseg v0.0.0-20221120141218-19f3806b842a // indirect
+require github.com/lovelydeng/uniseg v0.0.0-20221120141218-19f3806b842a
 
 require (
 	cloud.google.com/go/auth v0.9.9 // indirect
@@ -49,7 +50,7 @@ require (
 	go.opentelemetry.io/otel/metric v1.29.0 // indirect
 	go.opentelemetry.io/otel/trace v1.29.0 // indirect
 	golang.org/x/crypto v0.28.0 // indirect
-	golang.org/x/image v0.18.0 // indirect
+	golang.org/x/image v0.18.0
 	golang.org/x/net v0.30.0 // indirect
 	golang.org/x/sys v0.26.0 // indirect
 	golang.org/x/text v0.19.0 // indirect
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"time"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	"google.golang.org/api/calendar/v3"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+)
+
+// GoogleSource reads events from a Google calendar through the Calendar API.
+//
+// If SyncStatePath is set, the events are kept in that file together with the
+// API's sync token, so later runs only download the events that changed.
+type GoogleSource struct {
+	CalendarID    string
+	Auth          GoogleAuthConfig
+	SyncStatePath string
+
+	// opts replaces the authorized client, for tests.
+	opts []option.ClientOption
+}
+
+// googleSyncState is the locally stored event set of an incremental sync.
+type googleSyncState struct {
+	SyncToken string `json:"sync_token"`
+	// Since is the start of the full sync the event set was built from; it
+	// holds no events that ended earlier.
+	Since  time.Time                  `json:"since"`
+	Events map[string]*calendar.Event `json:"events"`
+}
+
+func (s GoogleSource) Events(ctx context.Context, start time.Time, end time.Time, tz *time.Location) ([]*Event, error) {
+	opts := s.opts
+	if opts == nil {
+		client, err := s.Auth.client(ctx)
+		if err != nil {
+			return nil, err
+		}
+		opts = []option.ClientOption{option.WithHTTPClient(client)}
+	}
+
+	srv, err := calendar.NewService(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to retrieve Calendar client: %w", err)
+	}
+
+	var items []*calendar.Event
+	if s.SyncStatePath == "" {
+		call := srv.Events.List(s.CalendarID).ShowDeleted(false).
+			SingleEvents(true).TimeMin(start.Format(time.RFC3339)).TimeMax(end.Format(time.RFC3339)).OrderBy("startTime")
+		items, _, err = listAll(ctx, call)
+		if err != nil {
+			return nil, fmt.Errorf("unable to retrieve events of %s: %w", s.CalendarID, err)
+		}
+	} else {
+		state, err := s.sync(ctx, srv, start)
+		if err != nil {
+			return nil, fmt.Errorf("unable to sync events of %s: %w", s.CalendarID, err)
+		}
+		for _, item := range state.Events {
+			items = append(items, item)
+		}
+	}
+
+	var allEvents []*Event
+	for _, item := range items {
+		e, err := NewEvent(item, tz)
+		if err != nil || !e.StartTime.Before(end) || e.EndTime.Before(start) {
+			continue
+		}
+		allEvents = append(allEvents, &e)
+	}
+	sort.SliceStable(allEvents, func(i, j int) bool {
+		return allEvents[i].StartTime.Before(allEvents[j].StartTime)
+	})
+	return allEvents, nil
+}
+
+// listAll follows every page of call, returning all items and the sync token
+// of the last page.
+func listAll(ctx context.Context, call *calendar.EventsListCall) ([]*calendar.Event, string, error) {
+	var items []*calendar.Event
+	pageToken := ""
+	for {
+		events, err := call.PageToken(pageToken).Context(ctx).Do()
+		if err != nil {
+			return nil, "", err
+		}
+		items = append(items, events.Items...)
+		if events.NextPageToken == "" {
+			return items, events.NextSyncToken, nil
+		}
+		pageToken = events.NextPageToken
+	}
+}
+
+// sync brings the stored event set up to date, falling back to a full sync
+// from start when there is no usable sync token.
+func (s GoogleSource) sync(ctx context.Context, srv *calendar.Service, start time.Time) (*googleSyncState, error) {
+	state := &googleSyncState{}
+	if b, err := os.ReadFile(s.SyncStatePath); err == nil {
+		if err := json.Unmarshal(b, state); err != nil {
+			log.Printf("Ignoring unreadable sync state %s: %v", s.SyncStatePath, err)
+			state = &googleSyncState{}
+		}
+	}
+
+	if state.SyncToken != "" && !state.Since.After(start) {
+		items, token, err := listAll(ctx, srv.Events.List(s.CalendarID).SingleEvents(true).SyncToken(state.SyncToken))
+		var apiErr *googleapi.Error
+		if errors.As(err, &apiErr) && apiErr.Code == http.StatusGone {
+			log.Printf("Sync token of %s expired, doing a full sync", s.CalendarID)
+			state.SyncToken = ""
+		} else if err != nil {
+			return nil, err
+		} else {
+			for _, item := range items {
+				if item.Status == "cancelled" {
+					delete(state.Events, item.Id)
+				} else {
+					state.Events[item.Id] = item
+				}
+			}
+			state.SyncToken = token
+		}
+	} else {
+		state.SyncToken = ""
+	}
+
+	if state.SyncToken != "" {
+		// Forget events that ended before the window, allowing a day for
+		// all-day events whose dates are read in UTC here.
+		for id, item := range state.Events {
+			if item.End == nil {
+				continue
+			}
+			if t, err := getTime(item.End, time.UTC, true); err == nil && t.Before(start.AddDate(0, 0, -1)) {
+				delete(state.Events, id)
+			}
+		}
+	} else {
+		items, token, err := listAll(ctx, srv.Events.List(s.CalendarID).ShowDeleted(false).
+			SingleEvents(true).TimeMin(start.Format(time.RFC3339)))
+		if err != nil {
+			return nil, err
+		}
+		state = &googleSyncState{SyncToken: token, Since: start, Events: make(map[string]*calendar.Event)}
+		for _, item := range items {
+			state.Events[item.Id] = item
+		}
+	}
+
+	b, err := json.Marshal(state)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(s.SyncStatePath, b, 0600); err != nil {
+		log.Printf("Unable to save sync state: %v", err)
+	}
+	return state, nil
+}
+
+// client returns an HTTP client authorized to read calendars.
+func (a GoogleAuthConfig) client(ctx context.Context) (*http.Client, error) {
+	if a.Type == "service_account" {
+		b, err := os.ReadFile(a.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read service account key file: %w", err)
+		}
+		config, err := google.JWTConfigFromJSON(b, calendar.CalendarReadonlyScope)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse service account key file: %w", err)
+		}
+		return config.Client(ctx), nil
+	}
+
+	b, err := os.ReadFile("credentials.json")
+	if err != nil {
+		return nil, fmt.Errorf("unable to read client secret file: %w", err)
+	}
+
+	// If modifying these scopes, delete your previously saved token.json.
+	config, err := deviceConfigFromJSON(b, calendar.CalendarReadonlyScope)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse client secret file to config: %w", err)
+	}
+	return getClient(config)
+}
+
+// Retrieve a token and returns the generated client.
+func getClient(config *oauth2.Config) (*http.Client, error) {
+	// The file token.json stores the user's access and refresh tokens, and is
+	// created by the auth command.
+	tokFile := "token.json"
+	tok, err := tokenFromFile(tokFile)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read %s, run `wallcalendar auth` to create it: %w", tokFile, err)
+	}
+	return config.Client(context.Background(), tok), nil
+}
+
+// Retrieves a token from a local file.
+func tokenFromFile(file string) (*oauth2.Token, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	tok := &oauth2.Token{}
+	err = json.NewDecoder(f).Decode(tok)
+	return tok, err
+}
+
+// Saves a token to a file path.
+func saveToken(path string, token *oauth2.Token) {
+	fmt.Printf("Saving credential file to: %s\n", path)
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		log.Fatalf("Unable to cache oauth token: %v", err)
+	}
+	defer f.Close()
+	json.NewEncoder(f).Encode(token)
+}
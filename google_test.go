@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/api/calendar/v3"
+	"google.golang.org/api/option"
+)
+
+func timedEvent(id string, summary string, start string, end string) *calendar.Event {
+	return &calendar.Event{
+		Id:      id,
+		Status:  "confirmed",
+		Summary: summary,
+		Start:   &calendar.EventDateTime{DateTime: start},
+		End:     &calendar.EventDateTime{DateTime: end},
+	}
+}
+
+// fakeCalendarAPI answers events.list with the pages queued for each
+// sync token, where "" is a full sync.
+type fakeCalendarAPI struct {
+	t        *testing.T
+	pages    map[string][]*calendar.Events
+	requests []string
+}
+
+func (f *fakeCalendarAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	f.requests = append(f.requests, q.Encode())
+	if !strings.HasSuffix(r.URL.Path, "/calendars/family/events") {
+		f.t.Errorf("unexpected path %s", r.URL.Path)
+	}
+	syncToken := q.Get("syncToken")
+	if syncToken != "" && (q.Get("timeMin") != "" || q.Get("orderBy") != "") {
+		f.t.Errorf("sync request with incompatible parameters: %s", q.Encode())
+	}
+	pages, ok := f.pages[syncToken]
+	if !ok {
+		w.WriteHeader(http.StatusGone)
+		json.NewEncoder(w).Encode(map[string]any{"error": map[string]any{"code": 410, "message": "Sync token is no longer valid"}})
+		return
+	}
+	page := 0
+	if p := q.Get("pageToken"); p != "" {
+		page = int(p[0] - '0')
+	}
+	json.NewEncoder(w).Encode(pages[page])
+}
+
+func TestGoogleSourceSync(t *testing.T) {
+	ny, _ := time.LoadLocation("America/New_York")
+	api := &fakeCalendarAPI{t: t, pages: map[string][]*calendar.Events{
+		"": {
+			{
+				Items:         []*calendar.Event{timedEvent("a", "Soccer", "2024-11-18T17:00:00-05:00", "2024-11-18T18:00:00-05:00")},
+				NextPageToken: "1",
+			},
+			{
+				Items: []*calendar.Event{
+					timedEvent("b", "Dentist", "2024-11-19T09:00:00-05:00", "2024-11-19T10:00:00-05:00"),
+					timedEvent("c", "Far future", "2025-06-01T09:00:00-04:00", "2025-06-01T10:00:00-04:00"),
+				},
+				NextSyncToken: "s1",
+			},
+		},
+		"s1": {
+			{
+				Items: []*calendar.Event{
+					{Id: "a", Status: "cancelled"},
+					timedEvent("b", "Dentist (moved)", "2024-11-20T09:00:00-05:00", "2024-11-20T10:00:00-05:00"),
+					timedEvent("d", "Recital", "2024-11-21T18:00:00-05:00", "2024-11-21T19:00:00-05:00"),
+				},
+				NextSyncToken: "s2",
+			},
+		},
+	}}
+	srv := httptest.NewServer(api)
+	defer srv.Close()
+
+	source := GoogleSource{
+		CalendarID:    "family",
+		SyncStatePath: filepath.Join(t.TempDir(), "sync.json"),
+		opts:          []option.ClientOption{option.WithEndpoint(srv.URL + "/"), option.WithHTTPClient(srv.Client())},
+	}
+	start := time.Date(2024, 11, 17, 0, 0, 0, 0, ny)
+	end := start.AddDate(0, 0, 28)
+
+	summaries := func() string {
+		events, err := source.Events(context.Background(), start, end, ny)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var s []string
+		for _, e := range events {
+			s = append(s, e.Summary)
+		}
+		return strings.Join(s, ",")
+	}
+
+	if got, want := summaries(), "Soccer,Dentist"; got != want {
+		t.Errorf("full sync = %q, want %q", got, want)
+	}
+	if got, want := summaries(), "Dentist (moved),Recital"; got != want {
+		t.Errorf("incremental sync = %q, want %q", got, want)
+	}
+	// s2 is unknown to the server, which answers 410 GONE and forces a
+	// full sync.
+	if got, want := summaries(), "Soccer,Dentist"; got != want {
+		t.Errorf("resync = %q, want %q", got, want)
+	}
+	if len(api.requests) != 6 {
+		t.Errorf("made %d requests, want 6: %v", len(api.requests), api.requests)
+	}
+}
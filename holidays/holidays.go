@@ -0,0 +1,164 @@
+// Package holidays computes the public holidays of a region from rules, so
+// they can be shown without subscribing to a holiday calendar.
+package holidays
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Holiday is a holiday on the day it is observed.
+type Holiday struct {
+	// Date is midnight of the day off.
+	Date time.Time
+	Name string
+}
+
+// rule is one holiday of a region.
+type rule struct {
+	name string
+	// date returns the month and day of the holiday in year.
+	date func(year int) (time.Month, int)
+	// observed holidays falling on a Saturday are taken the Friday before
+	// and those falling on a Sunday the Monday after.
+	observed bool
+	// since is the first year the holiday is kept, 0 for always.
+	since int
+}
+
+// fixed is a holiday on the same date every year.
+func fixed(m time.Month, day int) func(int) (time.Month, int) {
+	return func(int) (time.Month, int) { return m, day }
+}
+
+// nthWeekday is a holiday on the nth weekday wd of m, counted from the end
+// of the month if n is negative.
+func nthWeekday(m time.Month, wd time.Weekday, n int) func(int) (time.Month, int) {
+	return func(year int) (time.Month, int) {
+		if n < 0 {
+			last := time.Date(year, m+1, 0, 0, 0, 0, 0, time.UTC)
+			back := (int(last.Weekday()) - int(wd) + 7) % 7
+			return m, last.Day() - back + 7*(n+1)
+		}
+		first := time.Date(year, m, 1, 0, 0, 0, 0, time.UTC)
+		ahead := (int(wd) - int(first.Weekday()) + 7) % 7
+		return m, 1 + ahead + 7*(n-1)
+	}
+}
+
+// easterOffset is a holiday days after Easter Sunday, or before if negative.
+func easterOffset(days int) func(int) (time.Month, int) {
+	return func(year int) (time.Month, int) {
+		t := Easter(year).AddDate(0, 0, days)
+		return t.Month(), t.Day()
+	}
+}
+
+// Easter returns the date of Western Easter Sunday in year, by the anonymous
+// Gregorian algorithm.
+func Easter(year int) time.Time {
+	a := year % 19
+	b, c := year/100, year%100
+	d, e := b/4, b%4
+	f := (b + 8) / 25
+	g := (b - f + 1) / 3
+	h := (19*a + b - d - g + 15) % 30
+	i, k := c/4, c%4
+	l := (32 + 2*e + 2*i - h - k) % 7
+	m := (a + 11*h + 22*l) / 451
+	month := (h + l - 7*m + 114) / 31
+	day := (h+l-7*m+114)%31 + 1
+	return time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+}
+
+var regions = map[string][]rule{
+	// us are the US federal holidays.
+	"us": {
+		{name: "New Year's Day", date: fixed(time.January, 1), observed: true},
+		{name: "Martin Luther King Jr. Day", date: nthWeekday(time.January, time.Monday, 3)},
+		{name: "Washington's Birthday", date: nthWeekday(time.February, time.Monday, 3)},
+		{name: "Memorial Day", date: nthWeekday(time.May, time.Monday, -1)},
+		{name: "Juneteenth", date: fixed(time.June, 19), observed: true, since: 2021},
+		{name: "Independence Day", date: fixed(time.July, 4), observed: true},
+		{name: "Labor Day", date: nthWeekday(time.September, time.Monday, 1)},
+		{name: "Columbus Day", date: nthWeekday(time.October, time.Monday, 2)},
+		{name: "Veterans Day", date: fixed(time.November, 11), observed: true},
+		{name: "Thanksgiving Day", date: nthWeekday(time.November, time.Thursday, 4)},
+		{name: "Christmas Day", date: fixed(time.December, 25), observed: true},
+	},
+	// de are the public holidays of all of Germany.
+	"de": {
+		{name: "Neujahr", date: fixed(time.January, 1)},
+		{name: "Karfreitag", date: easterOffset(-2)},
+		{name: "Ostermontag", date: easterOffset(1)},
+		{name: "Tag der Arbeit", date: fixed(time.May, 1)},
+		{name: "Christi Himmelfahrt", date: easterOffset(39)},
+		{name: "Pfingstmontag", date: easterOffset(50)},
+		{name: "Tag der Deutschen Einheit", date: fixed(time.October, 3), since: 1990},
+		{name: "1. Weihnachtstag", date: fixed(time.December, 25)},
+		{name: "2. Weihnachtstag", date: fixed(time.December, 26)},
+	},
+}
+
+// Region is the set of holidays of a region. The zero Region has none.
+type Region struct {
+	rules []rule
+}
+
+// Get returns the region named name, us or de, or no holidays if name is
+// empty.
+func Get(name string) (Region, error) {
+	if name == "" {
+		return Region{}, nil
+	}
+	rules, ok := regions[strings.ToLower(name)]
+	if !ok {
+		return Region{}, fmt.Errorf("unknown holiday region %q", name)
+	}
+	return Region{rules: rules}, nil
+}
+
+// Year returns the holidays observed in year in loc, in date order. A
+// holiday may be observed in the year before, e.g. New Year's Day on a
+// Saturday.
+func (r Region) Year(year int, loc *time.Location) []Holiday {
+	var days []Holiday
+	for y := year; y <= year+1; y++ {
+		for _, rl := range r.rules {
+			if y < rl.since {
+				continue
+			}
+			m, d := rl.date(y)
+			date := time.Date(y, m, d, 0, 0, 0, 0, loc)
+			if rl.observed {
+				switch date.Weekday() {
+				case time.Saturday:
+					date = date.AddDate(0, 0, -1)
+				case time.Sunday:
+					date = date.AddDate(0, 0, 1)
+				}
+			}
+			if date.Year() == year {
+				days = append(days, Holiday{Date: date, Name: rl.name})
+			}
+		}
+	}
+	sort.SliceStable(days, func(i, j int) bool { return days[i].Date.Before(days[j].Date) })
+	return days
+}
+
+// Between returns the holidays from the day of start up to but not including
+// the day of end, in the location of start.
+func (r Region) Between(start time.Time, end time.Time) []Holiday {
+	var days []Holiday
+	for year := start.Year(); year <= end.Year(); year++ {
+		for _, h := range r.Year(year, start.Location()) {
+			if !h.Date.Before(start) && h.Date.Before(end) {
+				days = append(days, h)
+			}
+		}
+	}
+	return days
+}
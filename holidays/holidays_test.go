@@ -0,0 +1,136 @@
+package holidays
+
+import (
+	"testing"
+	"time"
+)
+
+func date(y int, m time.Month, d int) time.Time {
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+func TestEaster(t *testing.T) {
+	for _, want := range []time.Time{
+		date(2000, time.April, 23),
+		date(2008, time.March, 23),
+		date(2019, time.April, 21),
+		date(2024, time.March, 31),
+		date(2025, time.April, 20),
+		date(2038, time.April, 25),
+	} {
+		if got := Easter(want.Year()); !got.Equal(want) {
+			t.Errorf("Easter(%d) = %v, want %v", want.Year(), got.Format(time.DateOnly), want.Format(time.DateOnly))
+		}
+	}
+}
+
+func TestUSHolidays(t *testing.T) {
+	us, err := Get("US")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		year  int
+		count int
+		want  map[time.Time]string
+	}{
+		// Juneteenth is kept from 2021; Independence Day on a Saturday is
+		// taken on Friday.
+		{2020, 10, map[time.Time]string{
+			date(2020, time.January, 1): "New Year's Day",
+			date(2020, time.July, 3):    "Independence Day",
+			date(2020, time.May, 25):    "Memorial Day",
+		}},
+		// New Year's Day 2022 is a Saturday, so it is observed in 2021.
+		{2021, 12, map[time.Time]string{
+			date(2021, time.January, 18):  "Martin Luther King Jr. Day",
+			date(2021, time.June, 18):     "Juneteenth",
+			date(2021, time.July, 5):      "Independence Day",
+			date(2021, time.November, 25): "Thanksgiving Day",
+			date(2021, time.December, 24): "Christmas Day",
+			date(2021, time.December, 31): "New Year's Day",
+		}},
+		{2022, 10, map[time.Time]string{
+			date(2022, time.June, 20):     "Juneteenth",
+			date(2022, time.September, 5): "Labor Day",
+			date(2022, time.December, 26): "Christmas Day",
+		}},
+		{2023, 11, map[time.Time]string{
+			date(2023, time.January, 2):   "New Year's Day",
+			date(2023, time.October, 9):   "Columbus Day",
+			date(2023, time.November, 10): "Veterans Day",
+		}},
+		{2024, 11, map[time.Time]string{
+			date(2024, time.February, 19): "Washington's Birthday",
+			date(2024, time.May, 27):      "Memorial Day",
+			date(2024, time.November, 28): "Thanksgiving Day",
+		}},
+	}
+	for _, tt := range tests {
+		days := us.Year(tt.year, time.UTC)
+		if len(days) != tt.count {
+			t.Errorf("%d has %d holidays, want %d: %v", tt.year, len(days), tt.count, days)
+		}
+		got := make(map[time.Time]string)
+		for i, h := range days {
+			got[h.Date] = h.Name
+			if i > 0 && h.Date.Before(days[i-1].Date) {
+				t.Errorf("%d holidays out of order: %v", tt.year, days)
+			}
+		}
+		for d, name := range tt.want {
+			if got[d] != name {
+				t.Errorf("%s = %q, want %q", d.Format(time.DateOnly), got[d], name)
+			}
+		}
+	}
+}
+
+func TestGermanHolidays(t *testing.T) {
+	de, _ := Get("de")
+	want := map[time.Time]string{
+		date(2024, time.March, 29): "Karfreitag",
+		date(2024, time.April, 1):  "Ostermontag",
+		date(2024, time.May, 9):    "Christi Himmelfahrt",
+		date(2024, time.May, 20):   "Pfingstmontag",
+		date(2025, time.April, 18): "Karfreitag",
+		date(2025, time.June, 9):   "Pfingstmontag",
+	}
+	for d, name := range want {
+		days := de.Between(d, d.AddDate(0, 0, 1))
+		if len(days) != 1 || days[0].Name != name {
+			t.Errorf("%s = %v, want %q", d.Format(time.DateOnly), days, name)
+		}
+	}
+	// Holidays on a weekend are not moved.
+	if days := de.Between(date(2022, time.December, 25), date(2022, time.December, 27)); len(days) != 2 {
+		t.Errorf("Christmas 2022 = %v", days)
+	}
+}
+
+func TestBetween(t *testing.T) {
+	ny, _ := time.LoadLocation("America/New_York")
+	us, _ := Get("us")
+	start := time.Date(2021, time.December, 20, 0, 0, 0, 0, ny)
+	days := us.Between(start, start.AddDate(0, 0, 29))
+	want := []time.Time{
+		time.Date(2021, time.December, 24, 0, 0, 0, 0, ny),
+		time.Date(2021, time.December, 31, 0, 0, 0, 0, ny),
+		time.Date(2022, time.January, 17, 0, 0, 0, 0, ny),
+	}
+	if len(days) != len(want) {
+		t.Fatalf("Between() = %v, want %v", days, want)
+	}
+	for i := range want {
+		if !days[i].Date.Equal(want[i]) {
+			t.Errorf("holiday %d on %v, want %v", i, days[i].Date, want[i])
+		}
+	}
+
+	if days := (Region{}).Between(start, start.AddDate(1, 0, 0)); len(days) != 0 {
+		t.Errorf("no region has holidays %v", days)
+	}
+	if _, err := Get("atlantis"); err == nil {
+		t.Errorf("Get(atlantis) succeeded")
+	}
+}
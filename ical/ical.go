@@ -0,0 +1,441 @@
+// Package ical reads the parts of RFC 5545 iCalendar data needed to render a
+// calendar: VEVENT components, their start and end times and recurrences.
+package ical
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Event is a single VEVENT. Events read by Parse may carry a recurrence rule;
+// events returned by Calendar.Expand are always single instances.
+type Event struct {
+	UID         string
+	Summary     string
+	Location    string
+	Description string
+	URL         string
+	// Transparency is OPAQUE or TRANSPARENT.
+	Transparency string
+	Organizer    Attendee
+	Attendees    []Attendee
+	Start        time.Time
+	// End is exclusive, as in the iCalendar data. For all-day events it is the
+	// midnight after the last day.
+	End    time.Time
+	AllDay bool
+	Status string
+
+	RRule        *RRule
+	ExDates      []time.Time
+	RecurrenceID time.Time
+}
+
+// Attendee is an ORGANIZER or ATTENDEE of an event.
+type Attendee struct {
+	Email string
+	Name  string
+	// PartStat is the participation status, e.g. ACCEPTED or DECLINED.
+	PartStat string
+}
+
+func newAttendee(p property) Attendee {
+	email := p.value
+	if len(email) > len("mailto:") && strings.EqualFold(email[:len("mailto:")], "mailto:") {
+		email = email[len("mailto:"):]
+	}
+	return Attendee{Email: email, Name: p.params["CN"], PartStat: strings.ToUpper(p.params["PARTSTAT"])}
+}
+
+// Calendar is a parsed VCALENDAR object.
+type Calendar struct {
+	Events []*Event
+}
+
+type property struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+// Parse reads iCalendar data from r. Floating times and dates are interpreted
+// in tz, as are times whose TZID is neither an IANA nor a Windows zone name.
+func Parse(r io.Reader, tz *time.Location) (*Calendar, error) {
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, err
+	}
+	z := zones{tz: tz, known: make(map[string]*time.Location)}
+
+	cal := &Calendar{}
+	var cur *Event
+	var props []property
+	depth := 0
+	eventDepth := 0
+	for _, line := range lines {
+		p, err := parseProperty(line)
+		if err != nil {
+			return nil, err
+		}
+		switch p.name {
+		case "BEGIN":
+			depth++
+			if strings.EqualFold(p.value, "VEVENT") {
+				cur = &Event{}
+				props = nil
+				eventDepth = depth
+			}
+			continue
+		case "END":
+			depth--
+			if strings.EqualFold(p.value, "VEVENT") && cur != nil && depth == eventDepth-1 {
+				if err := cur.apply(props, z); err != nil {
+					return nil, err
+				}
+				cal.Events = append(cal.Events, cur)
+				cur = nil
+			}
+			continue
+		}
+		// Properties of nested components such as VALARM are skipped.
+		if cur != nil && depth == eventDepth {
+			props = append(props, p)
+		}
+	}
+	if depth != 0 {
+		return nil, fmt.Errorf("ical: unbalanced BEGIN/END")
+	}
+	return cal, nil
+}
+
+func (e *Event) apply(props []property, z zones) error {
+	var duration time.Duration
+	hasEnd := false
+	hasDuration := false
+	for _, p := range props {
+		var err error
+		switch p.name {
+		case "UID":
+			e.UID = p.value
+		case "SUMMARY":
+			e.Summary = unescape(p.value)
+		case "LOCATION":
+			e.Location = unescape(p.value)
+		case "DESCRIPTION":
+			e.Description = unescape(p.value)
+		case "URL":
+			e.URL = p.value
+		case "TRANSP":
+			e.Transparency = strings.ToUpper(p.value)
+		case "ORGANIZER":
+			e.Organizer = newAttendee(p)
+		case "ATTENDEE":
+			e.Attendees = append(e.Attendees, newAttendee(p))
+		case "STATUS":
+			e.Status = strings.ToUpper(p.value)
+		case "DTSTART":
+			e.Start, e.AllDay, err = parseDateTime(p, z)
+		case "DTEND":
+			e.End, _, err = parseDateTime(p, z)
+			hasEnd = true
+		case "DURATION":
+			duration, err = parseDuration(p.value)
+			hasDuration = true
+		case "RRULE":
+			e.RRule, err = ParseRRule(p.value)
+		case "EXDATE":
+			for _, v := range strings.Split(p.value, ",") {
+				var t time.Time
+				t, _, err = parseDateTime(property{name: p.name, params: p.params, value: v}, z)
+				if err != nil {
+					break
+				}
+				e.ExDates = append(e.ExDates, t)
+			}
+		case "RECURRENCE-ID":
+			e.RecurrenceID, _, err = parseDateTime(p, z)
+		}
+		if err != nil {
+			return fmt.Errorf("ical: %s in event %q: %w", p.name, e.UID, err)
+		}
+	}
+	if e.Start.IsZero() {
+		return fmt.Errorf("ical: event %q has no DTSTART", e.UID)
+	}
+	if !hasEnd {
+		switch {
+		case hasDuration && e.AllDay:
+			e.End = e.Start.AddDate(0, 0, int(duration/(24*time.Hour)))
+		case hasDuration:
+			e.End = e.Start.Add(duration)
+		case e.AllDay:
+			e.End = e.Start.AddDate(0, 0, 1)
+		default:
+			e.End = e.Start
+		}
+	}
+	// An end at or before the start is taken as no end: all-day events last
+	// their day and others are instants.
+	if !e.End.After(e.Start) {
+		if e.AllDay {
+			e.End = e.Start.AddDate(0, 0, 1)
+		} else {
+			e.End = e.Start
+		}
+	}
+	return nil
+}
+
+// unfold splits the content into logical lines, joining folded continuation
+// lines that begin with a space or tab.
+func unfold(r io.Reader) ([]string, error) {
+	var lines []string
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 64*1024), 1024*1024)
+	for s.Scan() {
+		line := strings.TrimRight(s.Text(), "\r")
+		if len(line) > 0 && (line[0] == ' ' || line[0] == '\t') && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		if len(line) == 0 {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines, s.Err()
+}
+
+func parseProperty(line string) (property, error) {
+	p := property{params: map[string]string{}}
+	inQuote := false
+	nameEnd := -1
+	for i, r := range line {
+		switch {
+		case r == '"':
+			inQuote = !inQuote
+		case r == ';' && !inQuote && nameEnd < 0:
+			nameEnd = i
+		case r == ':' && !inQuote:
+			head := line[:i]
+			p.value = line[i+1:]
+			if nameEnd < 0 {
+				p.name = strings.ToUpper(head)
+				return p, nil
+			}
+			p.name = strings.ToUpper(head[:nameEnd])
+			for _, param := range splitParams(head[nameEnd+1:]) {
+				k, v, _ := strings.Cut(param, "=")
+				p.params[strings.ToUpper(k)] = strings.Trim(v, `"`)
+			}
+			return p, nil
+		}
+	}
+	return p, fmt.Errorf("ical: malformed line %q", line)
+}
+
+func splitParams(s string) []string {
+	var out []string
+	inQuote := false
+	last := 0
+	for i, r := range s {
+		if r == '"' {
+			inQuote = !inQuote
+		} else if r == ';' && !inQuote {
+			out = append(out, s[last:i])
+			last = i + 1
+		}
+	}
+	return append(out, s[last:])
+}
+
+func unescape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i == len(s)-1 {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'n', 'N':
+			b.WriteByte('\n')
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+// zones resolves the TZIDs of one calendar. Floating times and dates, and
+// times in zones it does not know, are read in tz.
+type zones struct {
+	tz    *time.Location
+	known map[string]*time.Location
+}
+
+// location returns the zone named id, an IANA or a Windows zone name. Other
+// names fall back to tz, which is logged once per name.
+func (z zones) location(id string) *time.Location {
+	if loc, ok := z.known[id]; ok {
+		return loc
+	}
+	name := strings.TrimPrefix(id, "/")
+	if iana, ok := windowsZones[name]; ok {
+		name = iana
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		log.Printf("ical: unknown TZID %q, reading its times in %v", id, z.tz)
+		loc = z.tz
+	}
+	z.known[id] = loc
+	return loc
+}
+
+// parseDateTime parses a DATE or DATE-TIME value, reporting whether it was a
+// DATE.
+func parseDateTime(p property, z zones) (time.Time, bool, error) {
+	v := strings.TrimSpace(p.value)
+	if p.params["VALUE"] == "DATE" || len(v) == len("20060102") {
+		t, err := time.ParseInLocation("20060102", v, z.tz)
+		return t, true, err
+	}
+	if strings.HasSuffix(v, "Z") {
+		t, err := time.Parse("20060102T150405Z", v)
+		return t, false, err
+	}
+	loc := z.tz
+	if id, ok := p.params["TZID"]; ok {
+		loc = z.location(id)
+	}
+	t, err := time.ParseInLocation("20060102T150405", v, loc)
+	return t, false, err
+}
+
+// parseDuration parses an RFC 5545 DURATION such as "PT1H30M" or "-P1D".
+func parseDuration(s string) (time.Duration, error) {
+	orig := s
+	sign := time.Duration(1)
+	if strings.HasPrefix(s, "-") {
+		sign = -1
+		s = s[1:]
+	}
+	s = strings.TrimPrefix(s, "+")
+	if !strings.HasPrefix(s, "P") {
+		return 0, fmt.Errorf("bad duration %q", orig)
+	}
+	s = s[1:]
+	var d time.Duration
+	n := 0
+	digits := false
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			n = n*10 + int(r-'0')
+			digits = true
+			continue
+		}
+		unit := time.Duration(0)
+		switch r {
+		case 'W':
+			unit = 7 * 24 * time.Hour
+		case 'D':
+			unit = 24 * time.Hour
+		case 'H':
+			unit = time.Hour
+		case 'M':
+			unit = time.Minute
+		case 'S':
+			unit = time.Second
+		case 'T':
+			continue
+		default:
+			return 0, fmt.Errorf("bad duration %q", orig)
+		}
+		if !digits {
+			return 0, fmt.Errorf("bad duration %q", orig)
+		}
+		d += time.Duration(n) * unit
+		n = 0
+		digits = false
+	}
+	return sign * d, nil
+}
+
+// Expand returns the instances of all events that overlap [start, end),
+// sorted by start time. Recurring events are expanded with their RRULE, minus
+// EXDATEs, with RECURRENCE-ID overrides replacing the instances they modify.
+// Cancelled events and instances are dropped.
+func (c *Calendar) Expand(start time.Time, end time.Time) []Event {
+	overridden := make(map[string]bool)
+	for _, e := range c.Events {
+		if !e.RecurrenceID.IsZero() {
+			overridden[instanceKey(e.UID, e.RecurrenceID)] = true
+		}
+	}
+
+	var out []Event
+	for _, e := range c.Events {
+		if e.Status == "CANCELLED" {
+			continue
+		}
+		if e.RRule == nil || !e.RecurrenceID.IsZero() {
+			if e.overlaps(start, end) {
+				out = append(out, *e)
+			}
+			continue
+		}
+		for _, s := range e.RRule.Between(e.Start, end) {
+			if e.excluded(s) || overridden[instanceKey(e.UID, s)] {
+				continue
+			}
+			inst := *e
+			inst.RRule = nil
+			inst.ExDates = nil
+			inst.RecurrenceID = s
+			inst.Start = s
+			if e.AllDay {
+				inst.End = s.AddDate(0, 0, daysBetween(e.Start, e.End))
+			} else {
+				inst.End = s.Add(e.End.Sub(e.Start))
+			}
+			if inst.overlaps(start, end) {
+				out = append(out, inst)
+			}
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Start.Before(out[j].Start) })
+	return out
+}
+
+func instanceKey(uid string, t time.Time) string {
+	return uid + "/" + t.UTC().Format("20060102T150405Z")
+}
+
+func daysBetween(a time.Time, b time.Time) int {
+	return int(dateOf(b).Sub(dateOf(a)).Hours() / 24)
+}
+
+func (e *Event) excluded(t time.Time) bool {
+	for _, ex := range e.ExDates {
+		if ex.Equal(t) || (e.AllDay && dateOf(ex).Equal(dateOf(t))) {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *Event) overlaps(start time.Time, end time.Time) bool {
+	if !e.Start.Before(end) {
+		return false
+	}
+	if e.End.After(e.Start) {
+		return e.End.After(start)
+	}
+	return !e.Start.Before(start)
+}
@@ -0,0 +1,216 @@
+package ical
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func parseFixture(t *testing.T, name string, tz *time.Location) *Calendar {
+	t.Helper()
+	f, err := os.Open("testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	cal, err := Parse(f, tz)
+	if err != nil {
+		t.Fatalf("Parse(%s): %v", name, err)
+	}
+	return cal
+}
+
+func describe(events []Event, tz *time.Location) []string {
+	var out []string
+	for _, e := range events {
+		layout := "2006-01-02 15:04"
+		if e.AllDay {
+			layout = "2006-01-02"
+		}
+		out = append(out, e.Summary+" @ "+e.Start.In(tz).Format(layout)+" - "+e.End.In(tz).Format(layout))
+	}
+	return out
+}
+
+func TestExpandBasic(t *testing.T) {
+	ny, _ := time.LoadLocation("America/New_York")
+	cal := parseFixture(t, "basic.ics", ny)
+
+	start := time.Date(2024, 11, 17, 0, 0, 0, 0, ny)
+	got := describe(cal.Expand(start, start.AddDate(0, 0, 28)), ny)
+	want := []string{
+		"Dentist appointment for the whole family at the new office @ 2024-11-20 09:30 - 2024-11-20 10:30",
+		"Call with Oma, Opa @ 2024-11-21 13:00 - 2024-11-21 14:00",
+		"Beach trip @ 2024-11-22 - 2024-11-25",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Expand() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestExpandRecurring(t *testing.T) {
+	ny, _ := time.LoadLocation("America/New_York")
+	cal := parseFixture(t, "recurring.ics", ny)
+
+	start := time.Date(2024, 11, 10, 0, 0, 0, 0, ny)
+	got := describe(cal.Expand(start, start.AddDate(0, 0, 28)), ny)
+	want := []string{
+		"Swim meet @ 2024-11-11 09:00 - 2024-11-11 12:00",
+		"Swim practice @ 2024-11-13 16:30 - 2024-11-13 17:30",
+		"Standup @ 2024-11-18 09:00 - 2024-11-18 09:15",
+		"Swim practice @ 2024-11-18 16:30 - 2024-11-18 17:30",
+		"Grandma's birthday @ 2024-11-19 - 2024-11-20",
+		"Standup @ 2024-11-19 09:00 - 2024-11-19 09:15",
+		"Standup @ 2024-11-20 09:00 - 2024-11-20 09:15",
+		"Swim practice @ 2024-11-20 16:30 - 2024-11-20 17:30",
+		"Standup @ 2024-11-21 09:00 - 2024-11-21 09:15",
+		"Swim practice @ 2024-11-25 16:30 - 2024-11-25 17:30",
+		"Swim practice @ 2024-11-27 16:30 - 2024-11-27 17:30",
+		"Book club @ 2024-11-29 19:00 - 2024-11-29 21:00",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Expand() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestRRuleBetween(t *testing.T) {
+	ny, _ := time.LoadLocation("America/New_York")
+	tests := []struct {
+		rule    string
+		dtstart time.Time
+		want    []string
+	}{
+		{
+			rule:    "FREQ=DAILY;INTERVAL=2;COUNT=4",
+			dtstart: time.Date(2024, 3, 9, 8, 0, 0, 0, ny),
+			// Crosses the start of daylight saving time on March 10.
+			want: []string{"2024-03-09 08:00 EST", "2024-03-11 08:00 EDT", "2024-03-13 08:00 EDT", "2024-03-15 08:00 EDT"},
+		},
+		{
+			rule:    "FREQ=MONTHLY;BYMONTHDAY=31;COUNT=3",
+			dtstart: time.Date(2024, 1, 31, 12, 0, 0, 0, ny),
+			want:    []string{"2024-01-31 12:00 EST", "2024-03-31 12:00 EDT", "2024-05-31 12:00 EDT"},
+		},
+		{
+			rule:    "FREQ=YEARLY;BYMONTH=11;BYDAY=4TH;COUNT=3",
+			dtstart: time.Date(2024, 11, 28, 15, 0, 0, 0, ny),
+			want:    []string{"2024-11-28 15:00 EST", "2025-11-27 15:00 EST", "2026-11-26 15:00 EST"},
+		},
+		{
+			rule:    "FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1;COUNT=2",
+			dtstart: time.Date(2024, 8, 1, 9, 0, 0, 0, ny),
+			want:    []string{"2024-08-30 09:00 EDT", "2024-09-30 09:00 EDT"},
+		},
+		{
+			rule:    "FREQ=WEEKLY;INTERVAL=2;BYDAY=SA;UNTIL=20241130",
+			dtstart: time.Date(2024, 11, 2, 10, 0, 0, 0, ny),
+			want:    []string{"2024-11-02 10:00 EDT", "2024-11-16 10:00 EST", "2024-11-30 10:00 EST"},
+		},
+		{
+			rule:    "FREQ=YEARLY",
+			dtstart: time.Date(2024, 2, 29, 0, 0, 0, 0, ny),
+			want:    []string{"2024-02-29 00:00 EST", "2028-02-29 00:00 EST"},
+		},
+	}
+	for _, tt := range tests {
+		r, err := ParseRRule(tt.rule)
+		if err != nil {
+			t.Fatalf("ParseRRule(%q): %v", tt.rule, err)
+		}
+		var got []string
+		for _, s := range r.Between(tt.dtstart, time.Date(2029, 1, 1, 0, 0, 0, 0, ny)) {
+			got = append(got, s.Format("2006-01-02 15:04 MST"))
+		}
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("%s: got %v, want %v", tt.rule, got, tt.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, in := range []string{
+		"BEGIN:VCALENDAR\nBEGIN:VEVENT\nSUMMARY:no start\nEND:VEVENT\nEND:VCALENDAR\n",
+		"BEGIN:VCALENDAR\nBEGIN:VEVENT\nDTSTART:20241121T100000Z\nRRULE:COUNT=3\nEND:VEVENT\nEND:VCALENDAR\n",
+		"BEGIN:VCALENDAR\nBEGIN:VEVENT\nDTSTART:20241121T100000Z\n",
+		"BEGIN:VCALENDAR\nnot a property\nEND:VCALENDAR\n",
+	} {
+		if _, err := Parse(strings.NewReader(in), time.UTC); err == nil {
+			t.Errorf("Parse(%q) succeeded, want error", in)
+		}
+	}
+}
+
+func TestParseDetails(t *testing.T) {
+	cal := parseFixture(t, "basic.ics", time.UTC)
+	var call *Event
+	for _, e := range cal.Events {
+		if e.UID == "call@example.com" {
+			call = e
+		}
+	}
+	if call == nil {
+		t.Fatal("call not parsed")
+	}
+	if call.Location != "Kitchen, upstairs" || call.Description != "Bring the photos\nand the cake" || call.Transparency != "TRANSPARENT" {
+		t.Errorf("call = %+v", call)
+	}
+	if call.Organizer != (Attendee{Email: "oma@example.com", Name: "Oma"}) {
+		t.Errorf("organizer = %+v", call.Organizer)
+	}
+	want := []Attendee{
+		{Email: "alex@example.com", Name: "Alex", PartStat: "ACCEPTED"},
+		{Email: "opa@example.com", Name: "Opa, Sr.", PartStat: "DECLINED"},
+	}
+	if len(call.Attendees) != len(want) || call.Attendees[0] != want[0] || call.Attendees[1] != want[1] {
+		t.Errorf("attendees = %+v, want %+v", call.Attendees, want)
+	}
+}
+
+func TestParseEnds(t *testing.T) {
+	cal := parseFixture(t, "ends.ics", time.UTC)
+	got := describe(cal.Expand(time.Date(2024, 11, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC)), time.UTC)
+	want := []string{
+		"Birthday @ 2024-11-17 - 2024-11-18",
+		"Backwards @ 2024-11-18 - 2024-11-19",
+		"Call @ 2024-11-19 15:00 - 2024-11-19 15:00",
+		"Negative @ 2024-11-20 15:00 - 2024-11-20 15:00",
+	}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestParseWindowsZones(t *testing.T) {
+	var logged bytes.Buffer
+	log.SetOutput(&logged)
+	defer log.SetOutput(os.Stderr)
+
+	ny, _ := time.LoadLocation("America/New_York")
+	cal := parseFixture(t, "outlook.ics", ny)
+	starts := make(map[string]time.Time)
+	for _, e := range cal.Events {
+		starts[e.Summary] = e.Start
+	}
+	for summary, want := range map[string]time.Time{
+		"Standup": time.Date(2024, 11, 21, 14, 30, 0, 0, time.UTC),
+		"Review":  time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC),
+		// Unknown zones are read in the display zone.
+		"Custom": time.Date(2024, 11, 21, 17, 0, 0, 0, time.UTC),
+	} {
+		if !starts[summary].Equal(want) {
+			t.Errorf("%s starts %v, want %v", summary, starts[summary].UTC(), want)
+		}
+	}
+	if n := strings.Count(logged.String(), "Customized Time Zone"); n != 1 {
+		t.Errorf("unknown zone logged %d times, want once: %q", n, logged.String())
+	}
+
+	for windows, iana := range windowsZones {
+		if _, err := time.LoadLocation(iana); err != nil {
+			t.Errorf("%s: %v", windows, err)
+		}
+	}
+}
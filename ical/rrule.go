@@ -0,0 +1,362 @@
+package ical
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Frequency int
+
+const (
+	Daily Frequency = iota + 1
+	Weekly
+	Monthly
+	Yearly
+)
+
+// WeekdayNum is a BYDAY entry such as "TU" or "-1FR". N is zero when the rule
+// applies to every matching weekday.
+type WeekdayNum struct {
+	N   int
+	Day time.Weekday
+}
+
+// RRule is a parsed recurrence rule. Only the parts used by real-world
+// calendars are supported: FREQ from DAILY to YEARLY, INTERVAL, COUNT, UNTIL,
+// BYDAY, BYMONTHDAY, BYMONTH, BYSETPOS and WKST.
+type RRule struct {
+	Freq       Frequency
+	Interval   int
+	Count      int
+	Until      time.Time
+	ByDay      []WeekdayNum
+	ByMonthDay []int
+	ByMonth    []time.Month
+	BySetPos   []int
+	WeekStart  time.Weekday
+
+	untilIsDate     bool
+	untilIsFloating bool
+}
+
+// maxPeriods bounds the expansion of rules that never produce an instance.
+const maxPeriods = 100000
+
+var weekdays = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+// ParseRRule parses the value of an RRULE property.
+func ParseRRule(s string) (*RRule, error) {
+	r := &RRule{Interval: 1, WeekStart: time.Monday}
+	for _, part := range strings.Split(s, ";") {
+		k, v, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("bad rule part %q", part)
+		}
+		var err error
+		switch strings.ToUpper(k) {
+		case "FREQ":
+			switch strings.ToUpper(v) {
+			case "DAILY":
+				r.Freq = Daily
+			case "WEEKLY":
+				r.Freq = Weekly
+			case "MONTHLY":
+				r.Freq = Monthly
+			case "YEARLY":
+				r.Freq = Yearly
+			default:
+				return nil, fmt.Errorf("unsupported FREQ %q", v)
+			}
+		case "INTERVAL":
+			r.Interval, err = strconv.Atoi(v)
+			if err == nil && r.Interval < 1 {
+				err = fmt.Errorf("bad INTERVAL %q", v)
+			}
+		case "COUNT":
+			r.Count, err = strconv.Atoi(v)
+		case "UNTIL":
+			if len(v) == len("20060102") {
+				r.Until, err = time.Parse("20060102", v)
+				r.untilIsDate = true
+			} else if strings.HasSuffix(v, "Z") {
+				r.Until, err = time.Parse("20060102T150405Z", v)
+			} else {
+				r.Until, err = time.Parse("20060102T150405", v)
+				r.untilIsFloating = true
+			}
+		case "BYDAY":
+			for _, d := range strings.Split(v, ",") {
+				d = strings.ToUpper(strings.TrimSpace(d))
+				if len(d) < 2 {
+					return nil, fmt.Errorf("bad BYDAY %q", v)
+				}
+				wd, ok := weekdays[d[len(d)-2:]]
+				if !ok {
+					return nil, fmt.Errorf("bad BYDAY %q", v)
+				}
+				n := 0
+				if len(d) > 2 {
+					n, err = strconv.Atoi(d[:len(d)-2])
+					if err != nil {
+						break
+					}
+				}
+				r.ByDay = append(r.ByDay, WeekdayNum{N: n, Day: wd})
+			}
+		case "BYMONTHDAY":
+			r.ByMonthDay, err = parseInts(v)
+		case "BYMONTH":
+			var ms []int
+			ms, err = parseInts(v)
+			for _, m := range ms {
+				r.ByMonth = append(r.ByMonth, time.Month(m))
+			}
+		case "BYSETPOS":
+			r.BySetPos, err = parseInts(v)
+		case "WKST":
+			wd, ok := weekdays[strings.ToUpper(v)]
+			if !ok {
+				err = fmt.Errorf("bad WKST %q", v)
+			}
+			r.WeekStart = wd
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if r.Freq == 0 {
+		return nil, fmt.Errorf("rule %q has no FREQ", s)
+	}
+	return r, nil
+}
+
+func parseInts(s string) ([]int, error) {
+	var out []int
+	for _, p := range strings.Split(s, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(p))
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, n)
+	}
+	return out, nil
+}
+
+// Between returns the start times of the instances of a series starting at
+// dtstart, in order, up to but excluding end. COUNT is honored from dtstart so
+// the result is the same whatever end is.
+func (r *RRule) Between(dtstart time.Time, end time.Time) []time.Time {
+	loc := dtstart.Location()
+	until := r.Until
+	if r.untilIsDate {
+		until = time.Date(until.Year(), until.Month(), until.Day(), 23, 59, 59, 0, loc)
+	} else if r.untilIsFloating {
+		until = time.Date(until.Year(), until.Month(), until.Day(), until.Hour(), until.Minute(), until.Second(), 0, loc)
+	}
+
+	first := dateOf(dtstart)
+	var out []time.Time
+	count := 0
+	for k := 0; k < maxPeriods; k++ {
+		period := r.periodStart(first, k)
+		if !inLoc(period, dtstart).Before(end) {
+			break
+		}
+		for _, d := range r.candidates(period, dtstart) {
+			t := inLoc(d, dtstart)
+			if t.Before(dtstart) {
+				continue
+			}
+			if !until.IsZero() && t.After(until) {
+				return out
+			}
+			if r.Count > 0 && count >= r.Count {
+				return out
+			}
+			count++
+			if !t.Before(end) {
+				return out
+			}
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// dateOf returns the calendar date of t as midnight UTC, which keeps date
+// arithmetic free of daylight saving transitions.
+func dateOf(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// inLoc places the date d at the wall clock time of dtstart.
+func inLoc(d time.Time, dtstart time.Time) time.Time {
+	return time.Date(d.Year(), d.Month(), d.Day(), dtstart.Hour(), dtstart.Minute(), dtstart.Second(), 0, dtstart.Location())
+}
+
+func (r *RRule) periodStart(first time.Time, k int) time.Time {
+	switch r.Freq {
+	case Daily:
+		return first.AddDate(0, 0, k*r.Interval)
+	case Weekly:
+		back := (int(first.Weekday()) - int(r.WeekStart) + 7) % 7
+		return first.AddDate(0, 0, -back+7*k*r.Interval)
+	case Monthly:
+		return time.Date(first.Year(), first.Month()+time.Month(k*r.Interval), 1, 0, 0, 0, 0, time.UTC)
+	default:
+		return time.Date(first.Year()+k*r.Interval, time.January, 1, 0, 0, 0, 0, time.UTC)
+	}
+}
+
+func (r *RRule) candidates(period time.Time, dtstart time.Time) []time.Time {
+	var days []time.Time
+	switch r.Freq {
+	case Daily:
+		if r.matchMonth(period) && r.matchMonthDay(period) && r.matchWeekday(period) {
+			days = append(days, period)
+		}
+	case Weekly:
+		for i := 0; i < 7; i++ {
+			d := period.AddDate(0, 0, i)
+			if len(r.ByDay) == 0 && d.Weekday() != dtstart.Weekday() {
+				continue
+			}
+			if r.matchMonth(d) && r.matchWeekday(d) {
+				days = append(days, d)
+			}
+		}
+	case Monthly:
+		if r.matchMonth(period) {
+			days = r.monthDays(period, dtstart)
+		}
+	case Yearly:
+		switch {
+		case len(r.ByMonth) > 0:
+			for _, m := range r.ByMonth {
+				days = append(days, r.monthDays(time.Date(period.Year(), m, 1, 0, 0, 0, 0, time.UTC), dtstart)...)
+			}
+		case len(r.ByMonthDay) > 0:
+			for m := time.January; m <= time.December; m++ {
+				days = append(days, r.monthDays(time.Date(period.Year(), m, 1, 0, 0, 0, 0, time.UTC), dtstart)...)
+			}
+		case len(r.ByDay) > 0:
+			for d := period; d.Year() == period.Year(); d = d.AddDate(0, 0, 1) {
+				if r.matchOrdinalWeekday(d, period, period.AddDate(1, 0, -1)) {
+					days = append(days, d)
+				}
+			}
+		default:
+			d := time.Date(period.Year(), dtstart.Month(), dtstart.Day(), 0, 0, 0, 0, time.UTC)
+			if d.Day() == dtstart.Day() {
+				days = append(days, d)
+			}
+		}
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+	return r.applySetPos(days)
+}
+
+// monthDays returns the days of the month starting at first that match the
+// BYDAY and BYMONTHDAY parts, or dtstart's day of the month if neither is set.
+func (r *RRule) monthDays(first time.Time, dtstart time.Time) []time.Time {
+	last := first.AddDate(0, 1, -1)
+	var days []time.Time
+	if len(r.ByDay) == 0 && len(r.ByMonthDay) == 0 {
+		if dtstart.Day() <= last.Day() {
+			days = append(days, first.AddDate(0, 0, dtstart.Day()-1))
+		}
+		return days
+	}
+	for d := first; !d.After(last); d = d.AddDate(0, 0, 1) {
+		if r.matchMonthDay(d) && r.matchOrdinalWeekday(d, first, last) {
+			days = append(days, d)
+		}
+	}
+	return days
+}
+
+func (r *RRule) matchMonth(d time.Time) bool {
+	if len(r.ByMonth) == 0 {
+		return true
+	}
+	for _, m := range r.ByMonth {
+		if d.Month() == m {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *RRule) matchMonthDay(d time.Time) bool {
+	if len(r.ByMonthDay) == 0 {
+		return true
+	}
+	daysInMonth := time.Date(d.Year(), d.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+	for _, md := range r.ByMonthDay {
+		if md == d.Day() || md == d.Day()-daysInMonth-1 {
+			return true
+		}
+	}
+	return false
+}
+
+// matchWeekday checks BYDAY ignoring ordinals, as used by DAILY and WEEKLY.
+func (r *RRule) matchWeekday(d time.Time) bool {
+	if len(r.ByDay) == 0 {
+		return true
+	}
+	for _, wd := range r.ByDay {
+		if wd.Day == d.Weekday() {
+			return true
+		}
+	}
+	return false
+}
+
+// matchOrdinalWeekday checks BYDAY where "2TU" means the second Tuesday
+// between first and last.
+func (r *RRule) matchOrdinalWeekday(d time.Time, first time.Time, last time.Time) bool {
+	if len(r.ByDay) == 0 {
+		return true
+	}
+	fromStart := int(d.Sub(first).Hours()/24)/7 + 1
+	fromEnd := -(int(last.Sub(d).Hours()/24)/7 + 1)
+	for _, wd := range r.ByDay {
+		if wd.Day != d.Weekday() {
+			continue
+		}
+		if wd.N == 0 || wd.N == fromStart || wd.N == fromEnd {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *RRule) applySetPos(days []time.Time) []time.Time {
+	if len(r.BySetPos) == 0 {
+		return days
+	}
+	var out []time.Time
+	for _, pos := range r.BySetPos {
+		i := pos - 1
+		if pos < 0 {
+			i = len(days) + pos
+		}
+		if i >= 0 && i < len(days) {
+			out = append(out, days[i])
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Before(out[j]) })
+	return out
+}
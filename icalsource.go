@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+	"wallcalendar/ical"
+)
+
+// ICalSource reads events from an iCalendar (.ics) file, given either as a
+// local path or as an http(s) or webcal URL.
+type ICalSource struct {
+	Location string
+}
+
+func (s ICalSource) Events(ctx context.Context, start time.Time, end time.Time, tz *time.Location) ([]*Event, error) {
+	r, err := s.open(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	cal, err := ical.Parse(r, tz)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse %s: %w", s.Location, err)
+	}
+
+	var events []*Event
+	for _, item := range cal.Expand(start, end) {
+		e := NewEventFromICal(item, tz)
+		events = append(events, &e)
+	}
+	return events, nil
+}
+
+func (s ICalSource) open(ctx context.Context) (io.ReadCloser, error) {
+	url := s.Location
+	if strings.HasPrefix(url, "webcal://") {
+		url = "https://" + strings.TrimPrefix(url, "webcal://")
+	}
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		return os.Open(s.Location)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("unable to fetch %s: %s", s.Location, resp.Status)
+	}
+	return resp.Body, nil
+}
+
+// NewEventFromICal converts an expanded iCalendar instance into an Event the
+// same way NewEvent does for the Calendar API, including ending all-day events
+// one second before the following midnight.
+func NewEventFromICal(e ical.Event, tz *time.Location) Event {
+	id := e.UID
+	if !e.RecurrenceID.IsZero() {
+		if e.AllDay {
+			id += "_" + e.RecurrenceID.Format("20060102")
+		} else {
+			id += "_" + e.RecurrenceID.UTC().Format("20060102T150405Z")
+		}
+	}
+	recurringEventID := ""
+	if !e.RecurrenceID.IsZero() {
+		recurringEventID = e.UID
+	}
+	end := e.End.In(tz)
+	if e.AllDay {
+		end = end.Add(-1 * time.Second)
+	}
+	var attendees []Attendee
+	for _, a := range e.Attendees {
+		attendees = append(attendees, newAttendeeFromICal(a))
+	}
+	transparency := strings.ToLower(e.Transparency)
+	if transparency == "" {
+		transparency = "opaque"
+	}
+	return Event{
+		ID:               id,
+		Summary:          e.Summary,
+		Location:         e.Location,
+		Description:      e.Description,
+		StartTime:        e.Start.In(tz),
+		EndTime:          end,
+		IsAllDayEvent:    e.AllDay,
+		Status:           strings.ToLower(e.Status),
+		Transparency:     transparency,
+		Organizer:        newAttendeeFromICal(e.Organizer),
+		Attendees:        attendees,
+		RecurringEventID: recurringEventID,
+		HTMLLink:         e.URL,
+	}
+}
+
+// partStats maps iCalendar participation statuses to the Calendar API's
+// response statuses.
+var partStats = map[string]string{
+	"NEEDS-ACTION": "needsAction",
+	"ACCEPTED":     "accepted",
+	"DECLINED":     "declined",
+	"TENTATIVE":    "tentative",
+}
+
+func newAttendeeFromICal(a ical.Attendee) Attendee {
+	return Attendee{
+		Email:    a.Email,
+		Name:     a.Name,
+		Response: partStats[a.PartStat],
+	}
+}
@@ -0,0 +1,401 @@
+package main
+
+import (
+	"image"
+	"strings"
+	"time"
+	"wallcalendar/canvas"
+	"wallcalendar/holidays"
+
+	"golang.org/x/image/font"
+)
+
+const (
+	// cellHeaderHeight is the space above the first event of a cell, taken
+	// by the date.
+	cellHeaderHeight = 55
+	// rowBottomPadding is the space a row needs below its last event.
+	rowBottomPadding = 10
+	// compressedColumnWidth is the width of past days with past_days
+	// "compress", enough for the date.
+	compressedColumnWidth = 44
+	// holidayLines is the most lines a holiday name takes next to the date.
+	holidayLines = 2
+)
+
+// LayoutPlan is where everything of a grid view goes. Calendar.PlanLayout
+// measures all text once and Calendar.RenderLayout draws the plan as is.
+type LayoutPlan struct {
+	Rows []RowPlan
+}
+
+// RowPlan is one week row.
+type RowPlan struct {
+	// Start is the first day of the row.
+	Start  time.Time
+	Y      int
+	Height int
+	Days   []DayPlan
+}
+
+// DayPlan is one cell of a row.
+type DayPlan struct {
+	Date  time.Time
+	Col   int
+	Rect  image.Rectangle
+	Today bool
+	// Faded cells are greyed out.
+	Faded bool
+	// Past cells are days before today, de-emphasized as set by
+	// display.past_days.
+	Past bool
+	// Holiday names the holidays of the day, drawn in HolidayLines next to
+	// the date.
+	Holiday      string
+	HolidayLines []canvas.Line
+	// Events are the events drawn in the cell, in slot order.
+	Events []EventBox
+	// Hidden counts the events left out because they do not fit; the cell
+	// then shows a "+N more" line instead.
+	Hidden int
+}
+
+// EventBox is one event in a cell.
+type EventBox struct {
+	Event *Event
+	// Y is the baseline of the first line.
+	Y int
+	// Carryover events began on an earlier day of the row and are drawn as
+	// a line through the cell instead of their text.
+	Carryover bool
+	// Continued events began before the row and start with an arrow.
+	Continued bool
+	// Bar events are drawn as a bar across Days days from the cell, with
+	// the text in inverted colors. Ends is whether the event ends in the
+	// row. The days after the first are Carryover boxes of the bar.
+	Bar  bool
+	Days int
+	Ends bool
+	// Text is drawn in Lines, colored by Colors, and followed by Location
+	// in LocationLines.
+	Text          string
+	Colors        []canvas.ColorSpan
+	Lines         []canvas.Line
+	Location      string
+	LocationLines []canvas.Line
+}
+
+// Height returns the height of the event's text.
+func (b EventBox) Height(lineHeight int) int {
+	if b.Carryover {
+		return lineHeight
+	}
+	return (len(b.Lines) + len(b.LocationLines)) * lineHeight
+}
+
+// PlanLayout lays out the week rows of window below the day headers, sharing
+// the available height between rows by how much their events need.
+func (c Calendar) PlanLayout(window EventWindow, today time.Time, view View) LayoutPlan {
+	lineHeight := c.eventFace.Metrics().Height.Ceil()
+	eventPadding := int(float64(c.eventFace.Metrics().Height.Round()) * 0.5)
+
+	plan := LayoutPlan{Rows: make([]RowPlan, window.Weeks)}
+	region, _ := holidays.Get(c.display.Holidays)
+	holidayNames := make(map[string][]string)
+	for _, h := range region.Between(window.Start, window.Start.AddDate(0, 0, 7*window.Weeks)) {
+		key := h.Date.Format(time.DateOnly)
+		holidayNames[key] = append(holidayNames[key], h.Name)
+	}
+	slotHeights := make([]map[int]int, window.Weeks)
+	requiredHeights := make([]int, window.Weeks)
+	totalRequiredHeight := 0
+	for i := range plan.Rows {
+		row := &plan.Rows[i]
+		row.Start = window.Start.AddDate(0, 0, 7*i)
+		slotHeights[i] = make(map[int]int)
+		lefts, widths := c.rowColumns(row.Start, today, i == 0)
+		todayRow := !today.Before(row.Start) && today.Before(row.Start.AddDate(0, 0, 7))
+		maxSlot := -1
+		for j := 0; j < 7; j++ {
+			date := row.Start.AddDate(0, 0, j)
+			day := DayPlan{Date: date, Col: j, Today: date.Equal(today), Faded: view.Faded(today, date)}
+			day.Past = c.display.PastDays != "" && todayRow && date.Before(today)
+			day.Rect = image.Rect(lefts[j], 0, lefts[j]+widths[j], 0)
+			if names := holidayNames[date.Format(time.DateOnly)]; names != nil {
+				c.planHoliday(&day, strings.Join(names, ", "))
+			}
+			if day.Past && c.display.hidesPast() {
+				row.Days = append(row.Days, day)
+				continue
+			}
+			// Events going on from hidden past days show their text again
+			// today.
+			retitle := date.Equal(window.Start) || date.Equal(today) && c.display.hidesPast()
+			for _, e := range window.Dates[date] {
+				box := c.planEvent(e, date, j, retitle, window, widths)
+				day.Events = append(day.Events, box)
+				if day.Past {
+					continue
+				}
+				slot := e.SlotInWeek(i)
+				maxSlot = max(maxSlot, slot)
+				slotHeights[i][slot] = max(slotHeights[i][slot], box.Height(lineHeight))
+			}
+			row.Days = append(row.Days, day)
+		}
+
+		weekHeight := cellHeaderHeight
+		for s := 0; s <= maxSlot; s++ {
+			if h, ok := slotHeights[i][s]; ok {
+				weekHeight += h + eventPadding
+			} else {
+				weekHeight += lineHeight + eventPadding
+			}
+		}
+		weekHeight += rowBottomPadding
+		requiredHeights[i] = weekHeight
+		totalRequiredHeight += weekHeight
+	}
+
+	heights := shareHeight(requiredHeights, totalRequiredHeight, c.canv.Height()-headerHeight-margin, cellHeaderHeight+lineHeight+rowBottomPadding)
+
+	y := headerHeight + margin
+	for i := range plan.Rows {
+		row := &plan.Rows[i]
+		row.Y, row.Height = y, heights[i]
+		for j := range row.Days {
+			c.placeEvents(&row.Days[j], i, slotHeights[i], row.Y, row.Height)
+		}
+		c.clipRow(row, i, slotHeights[i])
+		y += row.Height
+	}
+	return plan
+}
+
+// planEvent returns the text of e on date, in column col of window, broken
+// into lines fitting the column widths of the row. Events that began earlier
+// show their text again if retitle is set.
+func (c Calendar) planEvent(e *Event, date time.Time, col int, retitle bool, window EventWindow, widths []int) EventBox {
+	if c.display.MultiDayBars != "" && e.MultiDay(c.tz) {
+		return c.planBar(e, date, col, retitle, window, widths)
+	}
+	box := EventBox{Event: e}
+	width := widths[col] - cellPadding*2
+	startsToday := e.StartsOnDate(date, c.tz)
+	if !startsToday && !retitle {
+		box.Carryover = true
+		return box
+	}
+	timePart := ""
+	if !startsToday {
+		// Add spacing for continuation arrow
+		timePart += "  "
+		box.Continued = true
+	}
+	if !e.IsAllDayEvent && startsToday {
+		timePart += c.timeLabel(e) + " "
+	}
+	timePart += c.untilLabel(e, window.Start, window.LastDay)
+	box.Text, box.Colors = c.eventText(e, timePart)
+	box.Lines = canvas.TruncateLines(box.Text, canvas.BreakLines(box.Text, width, c.eventFace), c.maxLines(e), width, c.eventFace)
+	if box.Location = c.locationLine(e); box.Location != "" {
+		box.LocationLines = canvas.BreakLines(box.Location, width, c.eventFace)
+	}
+	return box
+}
+
+// planBar returns the bar of the multi-day event e on date, in column col of
+// window. The bar starts on the event's first day in the row, so each row
+// shows the title again, or on date if retitle is set.
+func (c Calendar) planBar(e *Event, date time.Time, col int, retitle bool, window EventWindow, widths []int) EventBox {
+	box := EventBox{Event: e, Bar: true}
+	startsToday := e.StartsOnDate(date, c.tz)
+	if !startsToday && col > 0 && !retitle {
+		box.Carryover = true
+		return box
+	}
+	box.Continued = !startsToday
+	last := daysBetween(date, e.EndTime, c.tz)
+	box.Days = max(1, min(last+1, 7-col))
+	box.Ends = last < 7-col
+	box.Text = c.untilLabel(e, window.Start, window.LastDay) + c.eventLabel(e) + e.Summary
+	box.Colors = []canvas.ColorSpan{{Start: 0, Color: canvas.White}}
+	textWidth := barTextWidth(box, col, widths)
+	box.Lines = canvas.TruncateLines(box.Text, canvas.BreakLines(box.Text, textWidth, c.eventFace), c.maxLines(e), textWidth, c.eventFace)
+	return box
+}
+
+// planHoliday breaks the holiday name of day into the lines next to its
+// date. Cells too narrow to read it leave it out.
+func (c Calendar) planHoliday(day *DayPlan, name string) {
+	width := day.Rect.Dx() - font.MeasureString(c.dateFace, day.Date.Format("2")).Ceil() - cellPadding*3
+	if width < compressedColumnWidth {
+		return
+	}
+	day.Holiday = name
+	day.HolidayLines = canvas.TruncateLines(name, canvas.BreakLines(name, width, c.eventFace), holidayLines, width, c.eventFace)
+}
+
+// barTextWidth returns the width of the text of the bar b starting in column
+// col of a row with column widths.
+func barTextWidth(b EventBox, col int, widths []int) int {
+	w := 0
+	for _, cw := range widths[col : col+b.Days] {
+		w += cw
+	}
+	return w - cellPadding*4
+}
+
+// rowColumns returns the left edge and width of every column of the row
+// starting at start. With past_days "compress", the days before today in the
+// row of today are narrowed to make room for the rest if first is set, as
+// only the first row lines up with the day headers drawn over its columns.
+func (c Calendar) rowColumns(start time.Time, today time.Time, first bool) ([]int, []int) {
+	past := 0
+	if c.display.PastDays == "compress" && first && today.Before(start.AddDate(0, 0, 7)) {
+		past = max(0, daysBetween(start, today, c.tz))
+	}
+	lefts, widths := make([]int, 7), make([]int, 7)
+	x := c.columnLeft(0)
+	for j := range widths {
+		widths[j] = (c.ColumnWidth()*7 - past*compressedColumnWidth) / (7 - past)
+		if j < past {
+			widths[j] = compressedColumnWidth
+		}
+		if j == 6 {
+			// The last column takes what rounding left over.
+			widths[j] = c.columnLeft(7) - x
+		}
+		lefts[j] = x
+		x += widths[j]
+	}
+	return lefts, widths
+}
+
+// placeEvents sets the baseline of every event of day in the row at rowY,
+// stacking the slots of the row. Events of past days are cut to the height
+// of their slot.
+func (c Calendar) placeEvents(day *DayPlan, row int, slotHeights map[int]int, rowY int, rowHeight int) {
+	lineHeight := c.eventFace.Metrics().Height.Ceil()
+	eventPadding := int(float64(c.eventFace.Metrics().Height.Round()) * 0.5)
+	day.Rect.Min.Y, day.Rect.Max.Y = rowY, rowY+rowHeight
+
+	if day.Past {
+		for n := range day.Events {
+			b := &day.Events[n]
+			lines := max(1, slotHeights[b.Event.SlotInWeek(row)]/lineHeight)
+			if b.Bar {
+				b.Lines = b.Lines[:min(len(b.Lines), lines)]
+			} else {
+				b.Lines = canvas.TruncateLines(b.Text, b.Lines, lines, day.Rect.Dx()-cellPadding*2, c.eventFace)
+			}
+			b.LocationLines = b.LocationLines[:min(len(b.LocationLines), lines-len(b.Lines))]
+		}
+	}
+
+	for n := range day.Events {
+		y := rowY + cellHeaderHeight
+		for i := 0; i < day.Events[n].Event.SlotInWeek(row); i++ {
+			if h, ok := slotHeights[i]; ok {
+				y += h + eventPadding
+			} else {
+				y += lineHeight + eventPadding
+			}
+		}
+		day.Events[n].Y = y
+	}
+}
+
+// clipRow drops the events of the placed row that do not fit their cells. If
+// any event overflows a cell, its last line is reserved for a "+N more"
+// marker counting every event that does not fit above it. Bars are dropped
+// from every cell they span if they do not fit one of them, since they are
+// drawn across all of them.
+func (c Calendar) clipRow(row *RowPlan, i int, slotHeights map[int]int) {
+	lineHeight := c.eventFace.Metrics().Height.Ceil()
+	fits := func(b EventBox, bottom int) bool {
+		return b.Y+max(slotHeights[b.Event.SlotInWeek(i)], b.Height(lineHeight)) <= bottom
+	}
+	// cellBottom returns how far events of day reach with the bars of hidden
+	// left out.
+	cellBottom := func(day DayPlan, hidden map[*Event]bool) int {
+		for _, b := range day.Events {
+			if !fits(b, day.Rect.Max.Y) || b.Bar && hidden[b.Event] {
+				return day.Rect.Max.Y - lineHeight
+			}
+		}
+		return day.Rect.Max.Y
+	}
+
+	// Hiding a bar may make another cell reserve its last line and hide
+	// more bars, until no more are.
+	hidden := make(map[*Event]bool)
+	for changed := true; changed; {
+		changed = false
+		for _, day := range row.Days {
+			bottom := cellBottom(day, hidden)
+			for _, b := range day.Events {
+				if b.Bar && !hidden[b.Event] && !fits(b, bottom) {
+					hidden[b.Event] = true
+					changed = true
+				}
+			}
+		}
+	}
+
+	for j := range row.Days {
+		day := &row.Days[j]
+		bottom := cellBottom(*day, hidden)
+		var shown []EventBox
+		for _, b := range day.Events {
+			if fits(b, bottom) && !(b.Bar && hidden[b.Event]) {
+				shown = append(shown, b)
+			} else {
+				day.Hidden++
+			}
+		}
+		day.Events = shown
+	}
+}
+
+// shareHeight divides available between rows needing required heights, in
+// total total. Spare height is shared equally; if there is too little, rows
+// get their share of it but at least minHeight, taken from the tallest row.
+func shareHeight(required []int, total int, available int, minHeight int) []int {
+	heights := make([]int, len(required))
+	if total <= available {
+		extra := available - total
+		for i := range required {
+			heights[i] = required[i] + extra/len(required)
+		}
+		return heights
+	}
+
+	currentY := 0
+	for i := range required {
+		h := int(float64(required[i]) / float64(total) * float64(available))
+		heights[i] = h
+		// Adjust last one to fill exactly
+		if i == len(required)-1 {
+			heights[i] = available - currentY
+		}
+		currentY += h
+	}
+
+	// Clipped rows show a "+N more" line, so every row needs room for its
+	// date and that line.
+	for i := range heights {
+		if heights[i] >= minHeight {
+			continue
+		}
+		tallest := 0
+		for j := range heights {
+			if heights[j] > heights[tallest] {
+				tallest = j
+			}
+		}
+		heights[tallest] -= minHeight - heights[i]
+		heights[i] = minHeight
+	}
+	return heights
+}
@@ -0,0 +1,412 @@
+package main
+
+import (
+	"context"
+	"image"
+	"image/draw"
+	"testing"
+	"time"
+	"wallcalendar/canvas"
+	"wallcalendar/locale"
+
+	"github.com/furconz/freetype/truetype"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gomono"
+)
+
+func testCalendar(t *testing.T, tz *time.Location) Calendar {
+	t.Helper()
+	return testCalendarOn(t, image.NewRGBA(image.Rect(0, 0, 1304, 984)), tz)
+}
+
+// testCalendarOn returns a calendar drawing on dst.
+func testCalendarOn(t *testing.T, dst draw.Image, tz *time.Location) Calendar {
+	t.Helper()
+	f, err := truetype.Parse(gomono.TTF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	face := func(size float64) font.Face {
+		return truetype.NewFace(f, &truetype.Options{Size: size, DPI: 72, Hinting: font.HintingFull})
+	}
+	canv := canvas.NewCanvas(dst)
+	return NewCalendar(canv, face(70), face(24), face(16), face(11), tz, nil, nil, DisplayConfig{}, locale.English)
+}
+
+func findDay(t *testing.T, plan LayoutPlan, date time.Time) (int, DayPlan) {
+	t.Helper()
+	for i, row := range plan.Rows {
+		for _, day := range row.Days {
+			if day.Date.Equal(date) {
+				return i, day
+			}
+		}
+	}
+	t.Fatalf("%v is not in the plan", date)
+	return 0, DayPlan{}
+}
+
+func TestPlanLayout(t *testing.T) {
+	ny, _ := time.LoadLocation("America/New_York")
+	today := time.Date(2024, 11, 21, 0, 0, 0, 0, ny)
+	window, err := FetchEvents(today, TwoWeekView, time.Sunday, ICalSource{Location: "testdata/layout.ics"}, EventCache{}, nil, ny)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := testCalendar(t, ny)
+	plan := c.PlanLayout(window, today, TwoWeekView)
+
+	// The rows fill the height below the headers.
+	if len(plan.Rows) != 2 {
+		t.Fatalf("got %d rows, want 2", len(plan.Rows))
+	}
+	y := headerHeight + margin
+	for i, row := range plan.Rows {
+		if row.Y != y {
+			t.Errorf("row %d at y %d, want %d", i, row.Y, y)
+		}
+		y += row.Height
+	}
+	if y != c.canv.Height() {
+		t.Errorf("rows end at y %d, want %d", y, c.canv.Height())
+	}
+
+	// A long title is broken into lines that fit the cell.
+	_, day := findDay(t, plan, time.Date(2024, 11, 19, 0, 0, 0, 0, ny))
+	if len(day.Events) != 1 {
+		t.Fatalf("got %d events on Nov 19, want 1", len(day.Events))
+	}
+	talk := day.Events[0]
+	if talk.Text != "4:30pm  Parent teacher conference about the science fair project" || len(talk.Lines) < 3 {
+		t.Errorf("talk = %q in %d lines", talk.Text, len(talk.Lines))
+	}
+	for _, line := range talk.Lines {
+		if w := font.MeasureString(c.eventFace, talk.Text[line.Start:line.End]).Ceil(); w > day.Rect.Dx()-cellPadding*2 {
+			t.Errorf("line %q is %d wide, wider than the cell", talk.Text[line.Start:line.End], w)
+		}
+	}
+
+	// A multi-day event has its title on its first day and carries over
+	// into the rest of the row, and into the next row.
+	_, day = findDay(t, plan, time.Date(2024, 11, 20, 0, 0, 0, 0, ny))
+	if len(day.Events) != 1 || day.Events[0].Carryover || day.Events[0].Text != "Beach trip" {
+		t.Errorf("Nov 20 = %+v, want the titled trip", day.Events)
+	}
+	for _, d := range []int{21, 24, 26} {
+		_, day = findDay(t, plan, time.Date(2024, 11, d, 0, 0, 0, 0, ny))
+		if len(day.Events) != 1 || !day.Events[0].Carryover {
+			t.Errorf("Nov %d = %+v, want the trip carried over", d, day.Events)
+		}
+	}
+
+	// Events of the same slot share a baseline across the row.
+	_, day = findDay(t, plan, time.Date(2024, 11, 23, 0, 0, 0, 0, ny))
+	_, talkDay := findDay(t, plan, time.Date(2024, 11, 19, 0, 0, 0, 0, ny))
+	if day.Events[0].Y != talkDay.Events[0].Y {
+		t.Errorf("slot 0 at y %d and %d", day.Events[0].Y, talkDay.Events[0].Y)
+	}
+}
+
+func TestPlanLayoutClipsEvents(t *testing.T) {
+	ny, _ := time.LoadLocation("America/New_York")
+	today := time.Date(2024, 12, 4, 0, 0, 0, 0, ny)
+	window, err := FetchEvents(today, WeekView, time.Sunday, ICalSource{Location: "testdata/layout.ics"}, EventCache{}, nil, ny)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := testCalendar(t, ny)
+	plan := c.PlanLayout(window, today, WeekView)
+	lineHeight := c.eventFace.Metrics().Height.Ceil()
+
+	// The busy day shows what fits above a "+N more" line.
+	_, day := findDay(t, plan, today)
+	if !day.Today || day.Hidden == 0 || len(day.Events)+day.Hidden != 48 {
+		t.Errorf("Dec 4 shows %d and hides %d events, want 48 with some hidden", len(day.Events), day.Hidden)
+	}
+	for _, b := range day.Events {
+		if b.Y+b.Height(lineHeight) > day.Rect.Max.Y-lineHeight {
+			t.Errorf("event at y %d overlaps the +N more line of the row ending at %d", b.Y, day.Rect.Max.Y)
+		}
+	}
+}
+
+// staticSource returns its events whatever the window.
+type staticSource []*Event
+
+func (s staticSource) Events(ctx context.Context, start time.Time, end time.Time, tz *time.Location) ([]*Event, error) {
+	return s, nil
+}
+
+func TestPlanLayoutEndBeforeStart(t *testing.T) {
+	ny, _ := time.LoadLocation("America/New_York")
+	today := time.Date(2024, 11, 21, 0, 0, 0, 0, ny)
+	source := staticSource{
+		{ID: "call", Summary: "Call", StartTime: today.Add(9 * time.Hour), EndTime: today.Add(10 * time.Hour)},
+		{ID: "broken", Summary: "Broken", StartTime: today.Add(12 * time.Hour), EndTime: today.Add(-12 * time.Hour)},
+	}
+	window, err := FetchEvents(today, WeekView, time.Sunday, source, EventCache{}, nil, ny)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := testCalendar(t, ny)
+	_, day := findDay(t, c.PlanLayout(window, today, WeekView), today)
+	if len(day.Events) != 2 || day.Events[0].Y == day.Events[1].Y {
+		t.Errorf("Nov 21 = %+v, want both events on their own lines", day.Events)
+	}
+	for _, b := range day.Events {
+		if b.Event.SlotInWeek(0) < 0 {
+			t.Errorf("%s has no slot", b.Event.Summary)
+		}
+	}
+}
+
+func TestShareHeight(t *testing.T) {
+	tests := []struct {
+		required  []int
+		available int
+		want      []int
+	}{
+		{[]int{100, 200}, 400, []int{150, 250}},
+		{[]int{100, 300}, 200, []int{70, 130}},
+		{[]int{10, 990}, 500, []int{70, 430}},
+	}
+	for _, tt := range tests {
+		total := 0
+		for _, r := range tt.required {
+			total += r
+		}
+		got := shareHeight(tt.required, total, tt.available, 70)
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("shareHeight(%v, %d) = %v, want %v", tt.required, tt.available, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestPlanLayoutBars(t *testing.T) {
+	ny, _ := time.LoadLocation("America/New_York")
+	today := time.Date(2024, 11, 21, 0, 0, 0, 0, ny)
+	window, err := FetchEvents(today, TwoWeekView, time.Sunday, ICalSource{Location: "testdata/layout.ics"}, EventCache{}, nil, ny)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := testCalendar(t, ny)
+	c.display.MultiDayBars = "left"
+	plan := c.PlanLayout(window, today, TwoWeekView)
+
+	// The trip from Wednesday Nov 20 to Tuesday Nov 26 is a bar over the
+	// last four days of the first row, titled again over the first three
+	// days of the second.
+	_, day := findDay(t, plan, time.Date(2024, 11, 20, 0, 0, 0, 0, ny))
+	if bar := day.Events[0]; !bar.Bar || bar.Carryover || bar.Continued || bar.Days != 4 || bar.Ends || bar.Text != "Beach trip" {
+		t.Errorf("Nov 20 = %+v, want a bar over 4 days going on", bar)
+	}
+	_, day = findDay(t, plan, time.Date(2024, 11, 22, 0, 0, 0, 0, ny))
+	if bar := day.Events[0]; !bar.Bar || !bar.Carryover {
+		t.Errorf("Nov 22 = %+v, want the bar carried over", bar)
+	}
+	_, day = findDay(t, plan, time.Date(2024, 11, 24, 0, 0, 0, 0, ny))
+	if bar := day.Events[0]; !bar.Bar || bar.Carryover || !bar.Continued || bar.Days != 3 || !bar.Ends || bar.Text != "Beach trip" {
+		t.Errorf("Nov 24 = %+v, want a continued bar over 3 days", bar)
+	}
+
+	// Single-day events are not bars.
+	_, day = findDay(t, plan, time.Date(2024, 11, 19, 0, 0, 0, 0, ny))
+	if day.Events[0].Bar {
+		t.Errorf("Nov 19 = %+v, want no bar", day.Events[0])
+	}
+}
+
+func TestPlanLayoutOneDayBars(t *testing.T) {
+	ny, _ := time.LoadLocation("America/New_York")
+	today := time.Date(2024, 11, 17, 0, 0, 0, 0, ny)
+	sameDay, err := ICalSource{Location: "testdata/same-day.ics"}.Events(context.Background(), today, today.AddDate(0, 0, 7), ny)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// An all-day event ending before it starts, as DTEND = DTSTART used to
+	// be read.
+	early := &Event{ID: "early", Summary: "Early", StartTime: today.AddDate(0, 0, 1), EndTime: today.Add(-time.Second), IsAllDayEvent: true}
+	source := append(staticSource{early}, sameDay...)
+	window, err := FetchEvents(today, WeekView, time.Sunday, source, EventCache{}, nil, ny)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := testCalendar(t, ny)
+	c.display.MultiDayBars = "left"
+	plan := c.PlanLayout(window, today, WeekView)
+	c.RenderLayout(plan)
+
+	for _, date := range []time.Time{today, today.AddDate(0, 0, 1)} {
+		_, day := findDay(t, plan, date)
+		if len(day.Events) != 1 || day.Events[0].Bar {
+			t.Errorf("%s = %+v, want one event that is not a bar", date.Format(time.DateOnly), day.Events)
+		}
+	}
+}
+
+func TestClipRowBars(t *testing.T) {
+	ny, _ := time.LoadLocation("America/New_York")
+	c := testCalendar(t, ny)
+	lineHeight := c.eventFace.Metrics().Height.Ceil()
+	row := RowPlan{}
+	for j := 0; j < 7; j++ {
+		row.Days = append(row.Days, DayPlan{Col: j, Rect: image.Rect(j*100, 0, j*100+100, 200)})
+	}
+	// bar adds a bar over days from first in slot, ending on the cell's
+	// last line.
+	bar := func(first int, days int, slot int) *Event {
+		e := &Event{weekSlots: []int{slot}}
+		for j := first; j < first+days; j++ {
+			row.Days[j].Events = append(row.Days[j].Events, EventBox{Event: e, Y: 200 - lineHeight, Bar: true, Days: days, Carryover: j > first})
+		}
+		return e
+	}
+	trip := bar(0, 3, 0)
+	holiday := bar(4, 3, 0)
+	// Wednesday overflows and keeps its last line for "+N more", where the
+	// trip would go.
+	busy := &Event{weekSlots: []int{1}}
+	row.Days[2].Events = append(row.Days[2].Events, EventBox{Event: busy, Y: 200})
+
+	c.clipRow(&row, 0, map[int]int{0: lineHeight, 1: lineHeight})
+
+	for j, want := range []int{1, 1, 2, 0, 0, 0, 0} {
+		if got := row.Days[j].Hidden; got != want {
+			t.Errorf("day %d hides %d events, want %d", j, got, want)
+		}
+		for _, b := range row.Days[j].Events {
+			if b.Event == trip {
+				t.Errorf("day %d shows the trip, which is hidden on Wednesday", j)
+			}
+		}
+	}
+	for j := 4; j < 7; j++ {
+		if len(row.Days[j].Events) != 1 || row.Days[j].Events[0].Event != holiday {
+			t.Errorf("day %d = %+v, want the holiday bar", j, row.Days[j].Events)
+		}
+	}
+}
+
+func TestPlanLayoutPastDays(t *testing.T) {
+	ny, _ := time.LoadLocation("America/New_York")
+	today := time.Date(2024, 11, 21, 0, 0, 0, 0, ny)
+	window, err := FetchEvents(today, TwoWeekView, time.Sunday, ICalSource{Location: "testdata/layout.ics"}, EventCache{}, nil, ny)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := testCalendar(t, ny)
+	lineHeight := c.eventFace.Metrics().Height.Ceil()
+
+	// Hatched past days keep their events but do not make the row taller,
+	// so the long talk is cut to the one line of its slot.
+	c.display.PastDays = "hatch"
+	plan := c.PlanLayout(window, today, TwoWeekView)
+	_, day := findDay(t, plan, time.Date(2024, 11, 19, 0, 0, 0, 0, ny))
+	if !day.Past || len(day.Events) != 1 || day.Events[0].Height(lineHeight) != lineHeight {
+		t.Errorf("Nov 19 = %+v, want the talk cut to one line", day)
+	}
+	if _, day = findDay(t, plan, today); day.Past {
+		t.Errorf("today is past")
+	}
+
+	// Hidden past days drop their events, and the trip that began
+	// yesterday shows its title again today.
+	c.display.PastDays = "hide"
+	plan = c.PlanLayout(window, today, TwoWeekView)
+	for _, d := range []int{19, 20} {
+		if _, day = findDay(t, plan, time.Date(2024, 11, d, 0, 0, 0, 0, ny)); len(day.Events) != 0 {
+			t.Errorf("Nov %d = %+v, want no events", d, day.Events)
+		}
+	}
+	_, day = findDay(t, plan, today)
+	if len(day.Events) != 1 || day.Events[0].Carryover || !day.Events[0].Continued || day.Events[0].Text != "  Beach trip" {
+		t.Errorf("today = %+v, want the continued trip", day.Events)
+	}
+
+	// Compressed past days are narrow and the rest of the week shares the
+	// width they give up; the next week keeps the normal columns.
+	c.display.PastDays = "compress"
+	plan = c.PlanLayout(window, today, TwoWeekView)
+	first, second := plan.Rows[0].Days, plan.Rows[1].Days
+	for j := 0; j < 4; j++ {
+		if first[j].Rect.Dx() != compressedColumnWidth {
+			t.Errorf("past column %d is %d wide, want %d", j, first[j].Rect.Dx(), compressedColumnWidth)
+		}
+	}
+	if first[4].Rect.Dx() <= c.ColumnWidth() || first[4].Rect.Min.X != first[3].Rect.Max.X {
+		t.Errorf("today is at %v, want it wider and after the past days", first[4].Rect)
+	}
+	if first[6].Rect.Max.X != second[6].Rect.Max.X {
+		t.Errorf("rows end at %d and %d", first[6].Rect.Max.X, second[6].Rect.Max.X)
+	}
+	if second[0].Rect.Dx() != c.ColumnWidth() {
+		t.Errorf("next week column 0 is %d wide, want %d", second[0].Rect.Dx(), c.ColumnWidth())
+	}
+
+	// In a month only the days of the current week are past, not the
+	// earlier weeks.
+	window, err = FetchEvents(today, MonthView, time.Sunday, ICalSource{Location: "testdata/layout.ics"}, EventCache{}, nil, ny)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.display.PastDays = "hide"
+	plan = c.PlanLayout(window, today, MonthView)
+	for _, d := range []int{3, 12, 16} {
+		if _, day = findDay(t, plan, time.Date(2024, 11, d, 0, 0, 0, 0, ny)); day.Past {
+			t.Errorf("Nov %d is past", d)
+		}
+	}
+	if _, day = findDay(t, plan, time.Date(2024, 11, 19, 0, 0, 0, 0, ny)); !day.Past || len(day.Events) != 0 {
+		t.Errorf("Nov 19 = %+v, want a past day without events", day)
+	}
+}
+
+func TestPlanLayoutHolidays(t *testing.T) {
+	ny, _ := time.LoadLocation("America/New_York")
+	today := time.Date(2024, 11, 21, 0, 0, 0, 0, ny)
+	window, err := FetchEvents(today, TwoWeekView, time.Sunday, ICalSource{Location: "testdata/layout.ics"}, EventCache{}, nil, ny)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := testCalendar(t, ny)
+	if _, day := findDay(t, c.PlanLayout(window, today, TwoWeekView), time.Date(2024, 11, 28, 0, 0, 0, 0, ny)); day.Holiday != "" {
+		t.Errorf("Thanksgiving shown without a holiday region")
+	}
+
+	c.display.Holidays = "us"
+	plan := c.PlanLayout(window, today, TwoWeekView)
+	_, day := findDay(t, plan, time.Date(2024, 11, 28, 0, 0, 0, 0, ny))
+	if day.Holiday != "Thanksgiving Day" || len(day.HolidayLines) == 0 || len(day.HolidayLines) > holidayLines {
+		t.Errorf("Nov 28 = %q in %d lines, want Thanksgiving Day", day.Holiday, len(day.HolidayLines))
+	}
+	_, day = findDay(t, plan, time.Date(2024, 11, 27, 0, 0, 0, 0, ny))
+	if day.Holiday != "" {
+		t.Errorf("Nov 27 = %q, want no holiday", day.Holiday)
+	}
+}
+
+func TestPlanLayoutMaxLines(t *testing.T) {
+	ny, _ := time.LoadLocation("America/New_York")
+	today := time.Date(2024, 11, 21, 0, 0, 0, 0, ny)
+	window, err := FetchEvents(today, TwoWeekView, time.Sunday, ICalSource{Location: "testdata/layout.ics"}, EventCache{}, nil, ny)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := testCalendar(t, ny)
+	c.display.MaxLines = MaxLines{Events: 2, MultiDay: 1}
+	plan := c.PlanLayout(window, today, TwoWeekView)
+
+	// The long talk is cut to two lines ending in an ellipsis.
+	_, day := findDay(t, plan, time.Date(2024, 11, 19, 0, 0, 0, 0, ny))
+	talk := day.Events[0]
+	if len(talk.Lines) != 2 || talk.Lines[0].Ellipsis || !talk.Lines[1].Ellipsis {
+		t.Errorf("talk = %+v, want two lines ending in an ellipsis", talk.Lines)
+	}
+	_, day = findDay(t, plan, time.Date(2024, 11, 20, 0, 0, 0, 0, ny))
+	if trip := day.Events[0]; len(trip.Lines) != 1 || trip.Lines[0].Ellipsis {
+		t.Errorf("trip = %+v, want one whole line", trip.Lines)
+	}
+}
@@ -0,0 +1,216 @@
+// Package locale holds the month and weekday names and the date and time
+// formats of the languages the calendar can be shown in.
+package locale
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Locale is the language of the calendar's text.
+type Locale struct {
+	// Months are the month names, January first, as written inside a
+	// sentence.
+	Months [12]string
+	// Weekdays and ShortWeekdays are the weekday names, Sunday first.
+	Weekdays      [7]string
+	ShortWeekdays [7]string
+	// DayHeading formats a date from its weekday name (%[1]s), day of the
+	// month (%[2]d) and month name (%[3]s), e.g. "%[1]s, %[3]s %[2]d".
+	DayHeading string
+	// More formats the number of events left out of a cell, e.g. "+%d more".
+	More string
+	// LastSynced formats the time the shown events were fetched.
+	LastSynced string
+	// ShortDate formats a date from its abbreviated weekday name (%[1]s),
+	// day of the month (%[2]d) and month number (%[3]d), e.g.
+	// "%[1]s %[3]d/%[2]d".
+	ShortDate string
+	// Until formats the last day of an event, e.g. "until %s".
+	Until string
+	// HourLayout and MinuteLayout are the time.Format layouts of times on the
+	// hour and of other times, e.g. "3pm" and "3:04pm".
+	HourLayout   string
+	MinuteLayout string
+}
+
+var (
+	clock12 = [2]string{"3pm", "3:04pm"}
+	clock24 = [2]string{"15:04", "15:04"}
+)
+
+var locales = map[string]Locale{
+	"en": {
+		Months:        [12]string{"January", "February", "March", "April", "May", "June", "July", "August", "September", "October", "November", "December"},
+		Weekdays:      [7]string{"Sunday", "Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday"},
+		ShortWeekdays: [7]string{"Sun", "Mon", "Tue", "Wed", "Thu", "Fri", "Sat"},
+		DayHeading:    "%[1]s, %[3]s %[2]d",
+		More:          "+%d more",
+		LastSynced:    "last synced %s",
+		ShortDate:     "%[1]s %[3]d/%[2]d",
+		Until:         "until %s",
+		HourLayout:    clock12[0],
+		MinuteLayout:  clock12[1],
+	},
+	"de": {
+		Months:        [12]string{"Januar", "Februar", "März", "April", "Mai", "Juni", "Juli", "August", "September", "Oktober", "November", "Dezember"},
+		Weekdays:      [7]string{"Sonntag", "Montag", "Dienstag", "Mittwoch", "Donnerstag", "Freitag", "Samstag"},
+		ShortWeekdays: [7]string{"So", "Mo", "Di", "Mi", "Do", "Fr", "Sa"},
+		DayHeading:    "%[1]s, %[2]d. %[3]s",
+		More:          "+%d weitere",
+		LastSynced:    "zuletzt synchronisiert %s",
+		ShortDate:     "%[1]s %[2]d.%[3]d.",
+		Until:         "bis %s",
+		HourLayout:    clock24[0],
+		MinuteLayout:  clock24[1],
+	},
+	"fr": {
+		Months:        [12]string{"janvier", "février", "mars", "avril", "mai", "juin", "juillet", "août", "septembre", "octobre", "novembre", "décembre"},
+		Weekdays:      [7]string{"dimanche", "lundi", "mardi", "mercredi", "jeudi", "vendredi", "samedi"},
+		ShortWeekdays: [7]string{"dim", "lun", "mar", "mer", "jeu", "ven", "sam"},
+		DayHeading:    "%[1]s %[2]d %[3]s",
+		More:          "+%d autres",
+		LastSynced:    "synchronisé %s",
+		ShortDate:     "%[1]s %[2]d/%[3]d",
+		Until:         "jusqu'au %s",
+		HourLayout:    "15h",
+		MinuteLayout:  "15h04",
+	},
+	"es": {
+		Months:        [12]string{"enero", "febrero", "marzo", "abril", "mayo", "junio", "julio", "agosto", "septiembre", "octubre", "noviembre", "diciembre"},
+		Weekdays:      [7]string{"domingo", "lunes", "martes", "miércoles", "jueves", "viernes", "sábado"},
+		ShortWeekdays: [7]string{"dom", "lun", "mar", "mié", "jue", "vie", "sáb"},
+		DayHeading:    "%[1]s, %[2]d de %[3]s",
+		More:          "+%d más",
+		LastSynced:    "sincronizado %s",
+		ShortDate:     "%[1]s %[2]d/%[3]d",
+		Until:         "hasta el %s",
+		HourLayout:    clock24[0],
+		MinuteLayout:  clock24[1],
+	},
+	"nl": {
+		Months:        [12]string{"januari", "februari", "maart", "april", "mei", "juni", "juli", "augustus", "september", "oktober", "november", "december"},
+		Weekdays:      [7]string{"zondag", "maandag", "dinsdag", "woensdag", "donderdag", "vrijdag", "zaterdag"},
+		ShortWeekdays: [7]string{"zo", "ma", "di", "wo", "do", "vr", "za"},
+		DayHeading:    "%[1]s %[2]d %[3]s",
+		More:          "+%d meer",
+		LastSynced:    "gesynchroniseerd %s",
+		ShortDate:     "%[1]s %[2]d-%[3]d",
+		Until:         "t/m %s",
+		HourLayout:    clock24[0],
+		MinuteLayout:  clock24[1],
+	},
+}
+
+// English is the default locale.
+var English = locales["en"]
+
+// Get returns the locale named name, one of en, de, fr, es and nl, or English
+// if name is empty. clock is "12h" or "24h" to override the locale's time
+// format, or "" to keep it.
+func Get(name string, clock string) (Locale, error) {
+	l := English
+	if name != "" {
+		var ok bool
+		l, ok = locales[strings.ToLower(name)]
+		if !ok {
+			return Locale{}, fmt.Errorf("unknown locale %q", name)
+		}
+	}
+	switch clock {
+	case "":
+	case "12h":
+		l.HourLayout, l.MinuteLayout = clock12[0], clock12[1]
+	case "24h":
+		l.HourLayout, l.MinuteLayout = clock24[0], clock24[1]
+	default:
+		return Locale{}, fmt.Errorf("unknown clock %q, want 12h or 24h", clock)
+	}
+	return l, nil
+}
+
+// Month returns the name of m.
+func (l Locale) Month(m time.Month) string {
+	return l.Months[m-1]
+}
+
+// Weekday returns the name of d.
+func (l Locale) Weekday(d time.Weekday) string {
+	return l.Weekdays[d]
+}
+
+// ShortWeekday returns the abbreviated name of d.
+func (l Locale) ShortWeekday(d time.Weekday) string {
+	return l.ShortWeekdays[d]
+}
+
+// Time returns the time of day of t, e.g. "3pm", "3:30pm" or "15:30".
+func (l Locale) Time(t time.Time) string {
+	if t.Minute() == 0 {
+		return t.Format(l.HourLayout)
+	}
+	return t.Format(l.MinuteLayout)
+}
+
+// Range returns the times from start to end, e.g. "3–5pm", "11am–1pm" or
+// "15:00–17:00".
+func (l Locale) Range(start time.Time, end time.Time) string {
+	from, to := l.Time(start), l.Time(end)
+	for _, suffix := range []string{"am", "pm"} {
+		if strings.HasSuffix(from, suffix) && strings.HasSuffix(to, suffix) {
+			from = strings.TrimSuffix(from, suffix)
+		}
+	}
+	return from + "–" + to
+}
+
+// Duration returns d in hours and minutes, e.g. "45m", "2h" or "1h30m".
+func Duration(d time.Duration) string {
+	d = d.Round(time.Minute)
+	h, m := int(d.Hours()), int(d.Minutes())%60
+	switch {
+	case h == 0:
+		return fmt.Sprintf("%dm", m)
+	case m == 0:
+		return fmt.Sprintf("%dh", h)
+	}
+	return fmt.Sprintf("%dh%dm", h, m)
+}
+
+// ShortDay returns the date of t with its abbreviated weekday, e.g.
+// "Mon 12/2".
+func (l Locale) ShortDay(t time.Time) string {
+	return fmt.Sprintf(l.ShortDate, l.ShortWeekday(t.Weekday()), t.Day(), int(t.Month()))
+}
+
+// UntilDay returns the label of an event lasting until the day of t, e.g.
+// "until Mon 12/2".
+func (l Locale) UntilDay(t time.Time) string {
+	return fmt.Sprintf(l.Until, l.ShortDay(t))
+}
+
+// MonthTitle returns the heading of a view showing the months from start to
+// end, e.g. "November 2024", "January/February 2025" or
+// "December 2024/January 2025".
+func (l Locale) MonthTitle(start time.Time, end time.Time) string {
+	if start.Year() != end.Year() {
+		return fmt.Sprintf("%s %d/%s %d", capitalize(l.Month(start.Month())), start.Year(), capitalize(l.Month(end.Month())), end.Year())
+	}
+	if start.Month() != end.Month() {
+		return fmt.Sprintf("%s/%s %d", capitalize(l.Month(start.Month())), capitalize(l.Month(end.Month())), start.Year())
+	}
+	return fmt.Sprintf("%s %d", capitalize(l.Month(start.Month())), start.Year())
+}
+
+// Day returns the heading of the day of t, e.g. "Monday, January 2".
+func (l Locale) Day(t time.Time) string {
+	return capitalize(fmt.Sprintf(l.DayHeading, l.Weekday(t.Weekday()), t.Day(), l.Month(t.Month())))
+}
+
+func capitalize(s string) string {
+	r, n := utf8.DecodeRuneInString(s)
+	return string(unicode.ToUpper(r)) + s[n:]
+}
@@ -0,0 +1,111 @@
+package locale
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLocales(t *testing.T) {
+	at3 := time.Date(2025, 1, 20, 15, 0, 0, 0, time.UTC)
+	at330 := time.Date(2025, 1, 20, 15, 30, 0, 0, time.UTC)
+	feb := time.Date(2025, 2, 3, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		wantTitle string
+		wantDay   string
+		wantHour  string
+		wantTime  string
+		wantShort string
+	}{
+		{"en", "January/February 2025", "Monday, January 20", "3pm", "3:30pm", "Mon"},
+		{"de", "Januar/Februar 2025", "Montag, 20. Januar", "15:00", "15:30", "Mo"},
+		{"fr", "Janvier/Février 2025", "Lundi 20 janvier", "15h", "15h30", "lun"},
+		{"es", "Enero/Febrero 2025", "Lunes, 20 de enero", "15:00", "15:30", "lun"},
+		{"NL", "Januari/Februari 2025", "Maandag 20 januari", "15:00", "15:30", "ma"},
+	}
+	for _, tt := range tests {
+		l, err := Get(tt.name, "")
+		if err != nil {
+			t.Fatalf("Get(%q): %v", tt.name, err)
+		}
+		if got := l.MonthTitle(at3, feb); got != tt.wantTitle {
+			t.Errorf("%s: MonthTitle() = %q, want %q", tt.name, got, tt.wantTitle)
+		}
+		if got := l.Day(at3); got != tt.wantDay {
+			t.Errorf("%s: Day() = %q, want %q", tt.name, got, tt.wantDay)
+		}
+		if got := l.Time(at3); got != tt.wantHour {
+			t.Errorf("%s: Time(15:00) = %q, want %q", tt.name, got, tt.wantHour)
+		}
+		if got := l.Time(at330); got != tt.wantTime {
+			t.Errorf("%s: Time(15:30) = %q, want %q", tt.name, got, tt.wantTime)
+		}
+		if got := l.ShortWeekday(time.Monday); got != tt.wantShort {
+			t.Errorf("%s: ShortWeekday() = %q, want %q", tt.name, got, tt.wantShort)
+		}
+	}
+}
+
+func TestMonthTitle(t *testing.T) {
+	nov := time.Date(2024, 11, 1, 0, 0, 0, 0, time.UTC)
+	jan := time.Date(2025, 1, 4, 0, 0, 0, 0, time.UTC)
+	if got := English.MonthTitle(nov, nov.AddDate(0, 0, 20)); got != "November 2024" {
+		t.Errorf("MonthTitle() = %q", got)
+	}
+	if got := English.MonthTitle(nov.AddDate(0, 1, 20), jan); got != "December 2024/January 2025" {
+		t.Errorf("MonthTitle() = %q", got)
+	}
+}
+
+func TestGetClock(t *testing.T) {
+	at330 := time.Date(2025, 1, 20, 15, 30, 0, 0, time.UTC)
+	en, _ := Get("en", "24h")
+	if got := en.Time(at330); got != "15:30" {
+		t.Errorf("en 24h Time() = %q", got)
+	}
+	de, _ := Get("de", "12h")
+	if got := de.Time(at330); got != "3:30pm" {
+		t.Errorf("de 12h Time() = %q", got)
+	}
+	if _, err := Get("xx", ""); err == nil {
+		t.Errorf("Get(xx) succeeded")
+	}
+	if _, err := Get("en", "36h"); err == nil {
+		t.Errorf("Get(en, 36h) succeeded")
+	}
+}
+
+func TestRangeAndUntil(t *testing.T) {
+	at := func(h, m int) time.Time { return time.Date(2024, 12, 2, h, m, 0, 0, time.UTC) }
+	de, _ := Get("de", "")
+	fr, _ := Get("fr", "")
+	tests := []struct {
+		l          Locale
+		start, end time.Time
+		want       string
+	}{
+		{English, at(15, 0), at(17, 0), "3–5pm"},
+		{English, at(15, 30), at(17, 0), "3:30–5pm"},
+		{English, at(11, 0), at(13, 0), "11am–1pm"},
+		{de, at(15, 0), at(17, 30), "15:00–17:30"},
+		{fr, at(15, 0), at(17, 30), "15h–17h30"},
+	}
+	for _, tt := range tests {
+		if got := tt.l.Range(tt.start, tt.end); got != tt.want {
+			t.Errorf("Range(%v, %v) = %q, want %q", tt.start, tt.end, got, tt.want)
+		}
+	}
+
+	if got := English.UntilDay(at(0, 0)); got != "until Mon 12/2" {
+		t.Errorf("en UntilDay() = %q", got)
+	}
+	if got := de.UntilDay(at(0, 0)); got != "bis Mo 2.12." {
+		t.Errorf("de UntilDay() = %q", got)
+	}
+
+	for d, want := range map[time.Duration]string{45 * time.Minute: "45m", 2 * time.Hour: "2h", 90 * time.Minute: "1h30m", 26 * time.Hour: "26h"} {
+		if got := Duration(d); got != want {
+			t.Errorf("Duration(%v) = %q, want %q", d, got, want)
+		}
+	}
+}
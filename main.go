@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"image"
 	"image/png"
+	"log"
 	"os"
 	"strconv"
 	"strings"
@@ -17,10 +18,6 @@ import (
 	"golang.org/x/image/font/gofont/gomono"
 )
 
-const (
-	numWeeks = 4
-)
-
 func loadFont(ttf []byte) *truetype.Font {
 	font, err := truetype.Parse(ttf)
 	if err != nil {
@@ -29,40 +26,93 @@ func loadFont(ttf []byte) *truetype.Font {
 	return font
 }
 
-func loadFontFile(path string) *truetype.Font {
-	b, err := os.ReadFile(path)
-	if err != nil {
-		panic(err)
+// loadFontFiles reads and parses the TrueType fonts at paths.
+func loadFontFiles(paths []string) ([]*truetype.Font, error) {
+	var fonts []*truetype.Font
+	for _, path := range paths {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		f, err := truetype.Parse(b)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		fonts = append(fonts, f)
 	}
-	return loadFont(b)
+	return fonts, nil
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "auth" {
+		runAuth(os.Args[2:])
+		return
+	}
+
 	onlyRenderImage := flag.Bool("only_render_image", false, "Only render the image, no screen")
 	clearScreen := flag.Bool("clear_screen", false, "Clear the screen")
 	dateOverride := flag.String("date_override", "", "Date to use as today, e.g. 2024-11-21")
 	battery := flag.String("battery", "", "Battery output from pisugar")
+	configPath := flag.String("config", "config.json", "Path of the JSON config file")
+	cachePath := flag.String("cache", "events_cache.json", "File holding the events of the last successful fetch, empty to disable")
+	icsLocation := flag.String("ics", "", "Path or URL of an iCalendar (.ics) file to show instead of the configured calendars")
+	viewName := flag.String("view", "", "View to show instead of the configured one: four_weeks, week, two_weeks, month or agenda")
 	flag.Parse()
 
 	img := waveshare.NewHorizontalLSB(image.Rect(0, 0, 1304, 984))
 
-	newYork, _ := time.LoadLocation("America/New_York")
+	config, err := LoadConfig(*configPath)
+	if err != nil {
+		fmt.Println("Error loading config:", err)
+		return
+	}
+	if len(*icsLocation) > 0 {
+		config.Calendars = []CalendarConfig{{Name: "ics", ICS: *icsLocation}}
+	}
+	if len(*viewName) > 0 {
+		config.View = *viewName
+	}
+	view, err := ParseView(config.View)
+	if err != nil {
+		fmt.Println("Error selecting view:", err)
+		return
+	}
+	tz, secondaryTZ, err := config.Locations()
+	if err != nil {
+		fmt.Println("Error loading time zone:", err)
+		return
+	}
+	loc, err := config.Locale()
+	if err != nil {
+		fmt.Println("Error loading locale:", err)
+		return
+	}
 
-	today := midnight(time.Now().In(newYork), newYork)
+	today := midnight(time.Now().In(tz), tz)
 
 	if len(*dateOverride) > 0 {
-		var err error
-		today, err = time.ParseInLocation("2006-01-02", *dateOverride, newYork)
+		today, err = time.ParseInLocation("2006-01-02", *dateOverride, tz)
 		if err != nil {
 			fmt.Println("Error parsing date:", err)
 			return
 		}
-		today = midnight(today, newYork)
+		today = midnight(today, tz)
 	}
 
-	dateMap, start, lastday := FetchEvents(today, "family01175849838019336469@group.calendar.google.com", newYork)
+	rules, err := CompileRules(config.Rules)
+	if err != nil {
+		fmt.Println("Error compiling rules:", err)
+		return
+	}
+	window, err := FetchEvents(today, view, config.Display.FirstWeekday(), config.Sources(), EventCache{Path: *cachePath}, rules, tz)
+	if err != nil {
+		log.Fatalf("Unable to retrieve events: %v", err)
+	}
 	goMono := loadFont(gomono.TTF)
-	unifontMono := loadFontFile("fonts/UnifontExMono.ttf")
+	eventFonts, err := loadFontFiles(config.Fonts.Files())
+	if err != nil {
+		log.Fatalf("Unable to load fonts: %v", err)
+	}
 
 	canv := canvas.NewCanvas(img)
 	c := NewCalendar(
@@ -77,132 +127,45 @@ func main() {
 			DPI:     72,
 			Hinting: font.HintingFull,
 		}),
-		truetype.NewFace(unifontMono, &truetype.Options{
+		canvas.FallbackFace(&truetype.Options{
 			Size:    16,
 			DPI:     72,
 			Hinting: font.HintingFull,
-		}),
-		truetype.NewFace(unifontMono, &truetype.Options{
+		}, eventFonts...),
+		canvas.FallbackFace(&truetype.Options{
 			Size:    11,
 			DPI:     72,
 			Hinting: font.HintingFull,
-		}),
-		newYork)
-
-	if start.Month() == lastday.Month() {
-		c.RenderMonth(today.Format("January 2006"))
-	} else if start.Year() == lastday.Year() {
-		c.RenderMonth(fmt.Sprintf("%s/%s %s", start.Format("January"), lastday.Format("January"), start.Format("2006")))
-	} else {
-		c.RenderMonth(fmt.Sprintf("%s/%s", start.Format("January 2006"), lastday.Format("January 2006")))
-	}
-
-	c.RenderDayHeaders()
-	
-	// Calculate required height for each week
-	slotHeights := make([]map[int]int, numWeeks)
-	requiredHeights := make([]int, numWeeks)
-	totalRequiredHeight := 0
-	
-	// Standard face for measurement - MUST MATCH NewCalendar eventFace (unifontMono, 16)
-	face := truetype.NewFace(unifontMono, &truetype.Options{
-		Size:    16,
-		DPI:     72,
-		Hinting: font.HintingFull,
-	})
-	
-	for i := 0; i < numWeeks; i++ {
-		slotHeights[i] = make(map[int]int)
-		maxSlot := -1
-		
-		for j := 0; j < 7; j++ {
-			date := start.AddDate(0, 0, 7*i+j)
-			events := dateMap[date]
-			for _, e := range events {
-				if e.Slot > maxSlot {
-					maxSlot = e.Slot
-				}
-				
-				text := e.Summary
-				startsToday := e.StartsOnDate(date, newYork)
-				if !e.IsAllDayEvent && startsToday {
-					text = e.StartTimeShort(newYork) + " " + text
-				}
-				if !startsToday {
-					text = "  " + text
-				}
-				
-				w := c.ColumnWidth() - 10
-				h := canv.MeasureMultiColorString(text, w, face)
-				
-				if h > slotHeights[i][e.Slot] {
-					slotHeights[i][e.Slot] = h
-				}
-			}
-		}
-		
-		// Calculate total height for this week
-		// Header offset (55) + padding
-		weekHeight := 55
-		eventPadding := int(float64(face.Metrics().Height.Round()) * 0.5)
-		
-		for s := 0; s <= maxSlot; s++ {
-			if h, ok := slotHeights[i][s]; ok {
-				weekHeight += h + eventPadding
-			} else {
-				weekHeight += face.Metrics().Height.Ceil() + eventPadding
-			}
-		}
-		// Add a bit of bottom padding
-		weekHeight += 10
-		
-		requiredHeights[i] = weekHeight
-		totalRequiredHeight += weekHeight
-	}
-	
-	fmt.Printf("Required Heights: %v\n", requiredHeights)
-	fmt.Printf("Total Required: %d\n", totalRequiredHeight)
-	
-	// Distribute available height
-	// Total available height = 984 - headerHeight (140) - margin (8) = 836
-	availableHeight := 984 - 140 - 8
-	finalHeights := make([]int, numWeeks)
-	
-	// If we have enough space, give everyone what they need + extra
-	if totalRequiredHeight <= availableHeight {
-		extra := availableHeight - totalRequiredHeight
-		for i := 0; i < numWeeks; i++ {
-			finalHeights[i] = requiredHeights[i] + extra/numWeeks
-		}
-	} else {
-		// Not enough space, distribute proportionally
-		currentY := 0
-		for i := 0; i < numWeeks; i++ {
-			// Use float math for better precision then round
-			h := int(float64(requiredHeights[i]) / float64(totalRequiredHeight) * float64(availableHeight))
-			finalHeights[i] = h
-			// Adjust last one to fill exactly
-			if i == numWeeks-1 {
-				finalHeights[i] = availableHeight - currentY
-			}
-			currentY += h
-		}
+		}, eventFonts...),
+		tz,
+		secondaryTZ,
+		config.Styles(),
+		config.Display,
+		loc)
+
+	c.RenderMonth(view.Title(loc, today, window.Start, window.LastDay))
+	if window.Stale() {
+		c.RenderStaleBanner(window.SyncedAt, window.StaleCalendars)
 	}
-	
-	fmt.Printf("Final Heights: %v\n", finalHeights)
-
-	currentY := 140 + 8 // headerHeight + margin
-	for i := 0; i < numWeeks; i++ {
-		for j := 0; j < 7; j++ {
-			date := start.AddDate(0, 0, 7*i+j)
-			c.Render(j, i, date, dateMap[date], date == today, slotHeights[i], currentY, finalHeights[i])
-		}
-		currentY += finalHeights[i]
+	if view == AgendaView {
+		c.RenderAgenda(window, today)
+		render(img, c, *battery, *onlyRenderImage, *clearScreen)
+		return
 	}
 
-	batteryParts := strings.Split(*battery, " ")
+	plan := c.PlanLayout(window, today, view)
+	c.RenderDayHeaders(plan)
+	c.RenderLayout(plan)
+
+	render(img, c, *battery, *onlyRenderImage, *clearScreen)
+}
+
+// render adds the battery level and time to the calendar and shows img,
+// either on the screen or in processed.png.
+func render(img *waveshare.HorizontalLSB, c Calendar, battery string, onlyRenderImage bool, clearScreen bool) {
+	batteryParts := strings.Split(battery, " ")
 	if len(batteryParts) != 2 {
-		panic("Battery is wrong" + *battery)
+		panic("Battery is wrong" + battery)
 	}
 	num, err := strconv.ParseFloat(batteryParts[1], 64)
 	if err != nil {
@@ -211,14 +174,14 @@ func main() {
 
 	c.RenderBatteryAndTime(num)
 
-	if *onlyRenderImage {
+	if onlyRenderImage {
 		f, _ := os.Create("processed.png")
 		png.Encode(f, img)
 	} else {
 		waveshare.Initialize()
 		defer waveshare.Close()
 
-		if *clearScreen {
+		if clearScreen {
 			waveshare.Clear()
 			time.Sleep(300 * time.Millisecond)
 		}
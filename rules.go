@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// RuleConfig rewrites or hides the events matching all of its patterns.
+// Patterns are regular expressions; empty patterns match everything.
+type RuleConfig struct {
+	Summary  string `json:"summary,omitempty"`
+	Calendar string `json:"calendar,omitempty"`
+	// Status matches the event status: confirmed, tentative or cancelled.
+	Status string `json:"status,omitempty"`
+	// Response matches the calendar owner's response to an invitation:
+	// needsAction, declined, tentative or accepted.
+	Response string `json:"response,omitempty"`
+
+	// Action is one of "hide", "rename", "all_day" or "red".
+	Action string `json:"action"`
+	// Replace is the replacement for the Summary matches of a rename, and
+	// may refer to submatches as in regexp.Regexp.ReplaceAllString.
+	Replace string `json:"replace,omitempty"`
+}
+
+type RuleAction int
+
+const (
+	HideEvent RuleAction = iota + 1
+	RenameEvent
+	ForceAllDay
+	ForceRed
+)
+
+type Rule struct {
+	summary  *regexp.Regexp
+	calendar *regexp.Regexp
+	status   *regexp.Regexp
+	response *regexp.Regexp
+	action   RuleAction
+	replace  string
+}
+
+// CompileRules checks and compiles the configured rules.
+func CompileRules(configs []RuleConfig) ([]Rule, error) {
+	var rules []Rule
+	for i, rc := range configs {
+		r := Rule{replace: rc.Replace}
+		switch rc.Action {
+		case "hide":
+			r.action = HideEvent
+		case "rename":
+			r.action = RenameEvent
+			if rc.Summary == "" {
+				return nil, fmt.Errorf("rule %d: rename needs a summary pattern", i)
+			}
+		case "all_day":
+			r.action = ForceAllDay
+		case "red":
+			r.action = ForceRed
+		default:
+			return nil, fmt.Errorf("rule %d: unknown action %q", i, rc.Action)
+		}
+		for _, p := range []struct {
+			re      **regexp.Regexp
+			pattern string
+		}{
+			{&r.summary, rc.Summary},
+			{&r.calendar, rc.Calendar},
+			{&r.status, rc.Status},
+			{&r.response, rc.Response},
+		} {
+			if p.pattern == "" {
+				continue
+			}
+			re, err := regexp.Compile(p.pattern)
+			if err != nil {
+				return nil, fmt.Errorf("rule %d: %w", i, err)
+			}
+			*p.re = re
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+func (r Rule) matches(e *Event) bool {
+	for _, m := range []struct {
+		re *regexp.Regexp
+		s  string
+	}{
+		{r.summary, e.Summary},
+		{r.calendar, e.Calendar},
+		{r.status, e.Status},
+		{r.response, e.Response},
+	} {
+		if m.re != nil && !m.re.MatchString(m.s) {
+			return false
+		}
+	}
+	return true
+}
+
+// ApplyRules applies every rule in order to every event, returning the events
+// that were not hidden.
+func ApplyRules(rules []Rule, events []*Event) []*Event {
+	if len(rules) == 0 {
+		return events
+	}
+	var kept []*Event
+	for _, e := range events {
+		hidden := false
+		for _, r := range rules {
+			if !r.matches(e) {
+				continue
+			}
+			switch r.action {
+			case HideEvent:
+				hidden = true
+			case RenameEvent:
+				e.Summary = strings.TrimSpace(r.summary.ReplaceAllString(e.Summary, r.replace))
+			case ForceAllDay:
+				e.IsAllDayEvent = true
+			case ForceRed:
+				e.Highlight = true
+			}
+			if hidden {
+				break
+			}
+		}
+		if !hidden {
+			kept = append(kept, e)
+		}
+	}
+	return kept
+}
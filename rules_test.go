@@ -0,0 +1,67 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func fixtureEvents() []*Event {
+	start := time.Date(2024, 11, 21, 9, 0, 0, 0, time.UTC)
+	return []*Event{
+		{ID: "1", Calendar: "work", Summary: "Focus time", StartTime: start, EndTime: start.Add(2 * time.Hour), Status: "confirmed"},
+		{ID: "2", Calendar: "work", Summary: "[Team] Planning", StartTime: start, EndTime: start.Add(time.Hour), Status: "confirmed", Response: "accepted"},
+		{ID: "3", Calendar: "work", Summary: "Offsite", StartTime: start, EndTime: start.Add(time.Hour), Status: "confirmed", Response: "declined"},
+		{ID: "4", Calendar: "bookings", Summary: "Haircut", StartTime: start, EndTime: start.Add(time.Hour), Status: "tentative"},
+		{ID: "5", Calendar: "family", Summary: "Grandma visiting", StartTime: start, EndTime: start.Add(72 * time.Hour), Status: "confirmed"},
+	}
+}
+
+func TestApplyRules(t *testing.T) {
+	rules, err := CompileRules([]RuleConfig{
+		{Summary: "^Focus time$", Action: "hide"},
+		{Response: "declined", Action: "hide"},
+		{Summary: `^\[Team\]\s*`, Action: "rename", Replace: ""},
+		{Calendar: "bookings", Status: "tentative", Action: "red"},
+		{Calendar: "family", Summary: "visiting", Action: "all_day"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := ApplyRules(rules, fixtureEvents())
+
+	want := []Event{
+		{ID: "2", Summary: "Planning"},
+		{ID: "4", Summary: "Haircut", Highlight: true},
+		{ID: "5", Summary: "Grandma visiting", IsAllDayEvent: true},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d events, want %d", len(got), len(want))
+	}
+	for i, w := range want {
+		g := got[i]
+		if g.ID != w.ID || g.Summary != w.Summary || g.Highlight != w.Highlight || g.IsAllDayEvent != w.IsAllDayEvent {
+			t.Errorf("event %d = %+v, want %+v", i, *g, w)
+		}
+	}
+}
+
+func TestApplyRulesWithoutRules(t *testing.T) {
+	events := fixtureEvents()
+	if got := ApplyRules(nil, events); len(got) != len(events) {
+		t.Errorf("got %d events, want %d", len(got), len(events))
+	}
+}
+
+func TestCompileRulesErrors(t *testing.T) {
+	for _, rc := range []RuleConfig{
+		{Summary: "x", Action: "explode"},
+		{Action: "rename", Replace: "y"},
+		{Summary: "(", Action: "hide"},
+		{Calendar: "[", Action: "red"},
+	} {
+		if _, err := CompileRules([]RuleConfig{rc}); err == nil {
+			t.Errorf("CompileRules(%+v) succeeded, want error", rc)
+		}
+	}
+}
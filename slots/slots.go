@@ -0,0 +1,115 @@
+// Package slots assigns events to the lines ("slots") of a calendar's week
+// rows, so that events on the same day never share a slot and an event
+// spanning several days keeps one slot for the whole row.
+package slots
+
+import (
+	"sort"
+	"time"
+)
+
+// DaysPerWeek is the number of days in a week row.
+const DaysPerWeek = 7
+
+// Span is an event as seen by the allocator.
+type Span struct {
+	// First and Last are the first and last day of the event, inclusive,
+	// counted from the first day of the view. They may lie outside it. A
+	// Last before First is taken as First, so every event keeps its first
+	// day.
+	First int
+	Last  int
+	// AllDay events are placed before timed events.
+	AllDay bool
+	// Start orders events that are otherwise equal.
+	Start time.Time
+	// Key breaks the remaining ties so the result does not depend on the
+	// order of the input.
+	Key string
+}
+
+// Assign lays out spans over weeks week rows. It returns, for every span, its
+// slot in every week row, or -1 for rows it does not appear in.
+//
+// Each row is packed independently: multi-day and all-day events first,
+// then longer ones, then by start. Every event takes the lowest slot that is
+// free on all of its days in the row.
+func Assign(spans []Span, weeks int) [][]int {
+	result := make([][]int, len(spans))
+	for i := range result {
+		result[i] = make([]int, weeks)
+		for w := range result[i] {
+			result[i][w] = -1
+		}
+	}
+
+	for w := 0; w < weeks; w++ {
+		rowFirst := w * DaysPerWeek
+		rowLast := rowFirst + DaysPerWeek - 1
+
+		type clipped struct {
+			index int
+			first int
+			last  int
+		}
+		var row []clipped
+		for i, s := range spans {
+			last := max(s.Last, s.First)
+			if last < rowFirst || s.First > rowLast {
+				continue
+			}
+			row = append(row, clipped{i, max(s.First, rowFirst), min(last, rowLast)})
+		}
+
+		sort.Slice(row, func(a, b int) bool {
+			sa, sb := spans[row[a].index], spans[row[b].index]
+			wideA := sa.AllDay || sa.Last > sa.First
+			wideB := sb.AllDay || sb.Last > sb.First
+			if wideA != wideB {
+				return wideA
+			}
+			lenA := row[a].last - row[a].first
+			lenB := row[b].last - row[b].first
+			if lenA != lenB {
+				return lenA > lenB
+			}
+			if row[a].first != row[b].first {
+				return row[a].first < row[b].first
+			}
+			if !sa.Start.Equal(sb.Start) {
+				return sa.Start.Before(sb.Start)
+			}
+			if sa.Key != sb.Key {
+				return sa.Key < sb.Key
+			}
+			return row[a].index < row[b].index
+		})
+
+		var used [DaysPerWeek][]bool
+		for _, c := range row {
+			slot := 0
+			for ; ; slot++ {
+				free := true
+				for d := c.first; d <= c.last; d++ {
+					day := used[d-rowFirst]
+					if slot < len(day) && day[slot] {
+						free = false
+						break
+					}
+				}
+				if free {
+					break
+				}
+			}
+			for d := c.first; d <= c.last; d++ {
+				day := &used[d-rowFirst]
+				for len(*day) <= slot {
+					*day = append(*day, false)
+				}
+				(*day)[slot] = true
+			}
+			result[c.index][w] = slot
+		}
+	}
+	return result
+}
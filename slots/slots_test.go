@@ -0,0 +1,204 @@
+package slots
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var base = time.Date(2024, 11, 17, 0, 0, 0, 0, time.UTC)
+
+func at(day int, hour int) time.Time {
+	return base.AddDate(0, 0, day).Add(time.Duration(hour) * time.Hour)
+}
+
+func TestAssign(t *testing.T) {
+	tests := []struct {
+		name  string
+		spans []Span
+		weeks int
+		want  [][]int
+	}{
+		{
+			name:  "empty",
+			spans: nil,
+			weeks: 2,
+			want:  [][]int{},
+		},
+		{
+			name: "timed events on one day stack by start time",
+			spans: []Span{
+				{First: 1, Last: 1, Start: at(1, 15), Key: "b"},
+				{First: 1, Last: 1, Start: at(1, 9), Key: "a"},
+			},
+			weeks: 1,
+			want:  [][]int{{1}, {0}},
+		},
+		{
+			name: "multi-day event starting later is placed first",
+			spans: []Span{
+				{First: 2, Last: 2, Start: at(2, 8), Key: "breakfast"},
+				{First: 2, Last: 4, Start: at(2, 18), Key: "trip"},
+			},
+			weeks: 1,
+			want:  [][]int{{1}, {0}},
+		},
+		{
+			name: "all-day before timed",
+			spans: []Span{
+				{First: 3, Last: 3, Start: at(3, 0), Key: "meeting"},
+				{First: 3, Last: 3, AllDay: true, Start: at(3, 0), Key: "birthday"},
+			},
+			weeks: 1,
+			want:  [][]int{{1}, {0}},
+		},
+		{
+			name: "longer spans first",
+			spans: []Span{
+				{First: 1, Last: 2, AllDay: true, Start: at(1, 0), Key: "short"},
+				{First: 1, Last: 5, AllDay: true, Start: at(1, 0), Key: "long"},
+			},
+			weeks: 1,
+			want:  [][]int{{1}, {0}},
+		},
+		{
+			name: "multi-day event keeps its slot across the row",
+			spans: []Span{
+				{First: 0, Last: 0, Start: at(0, 9), Key: "sun"},
+				{First: 1, Last: 1, Start: at(1, 9), Key: "mon"},
+				{First: 1, Last: 3, AllDay: true, Start: at(1, 0), Key: "camp"},
+				{First: 3, Last: 3, Start: at(3, 9), Key: "wed"},
+			},
+			weeks: 1,
+			want:  [][]int{{0}, {1}, {0}, {1}},
+		},
+		{
+			name: "event across a week boundary is re-packed",
+			spans: []Span{
+				{First: 5, Last: 8, AllDay: true, Start: at(5, 0), Key: "visit"},
+				{First: 3, Last: 6, AllDay: true, Start: at(3, 0), Key: "conference"},
+			},
+			weeks: 2,
+			want:  [][]int{{1, 0}, {0, -1}},
+		},
+		{
+			name: "spans outside the view are clipped or dropped",
+			spans: []Span{
+				{First: -3, Last: 1, AllDay: true, Start: at(-3, 0), Key: "before"},
+				{First: 12, Last: 30, AllDay: true, Start: at(12, 0), Key: "after"},
+				{First: 40, Last: 41, AllDay: true, Start: at(40, 0), Key: "outside"},
+			},
+			weeks: 2,
+			want:  [][]int{{0, -1}, {-1, 0}, {-1, -1}},
+		},
+		{
+			name: "free slot is reused",
+			spans: []Span{
+				{First: 0, Last: 1, AllDay: true, Start: at(0, 0), Key: "a"},
+				{First: 0, Last: 0, Start: at(0, 9), Key: "b"},
+				{First: 2, Last: 2, Start: at(2, 9), Key: "c"},
+			},
+			weeks: 1,
+			want:  [][]int{{0}, {1}, {0}},
+		},
+		{
+			name: "span ending before it starts keeps its first day",
+			spans: []Span{
+				{First: 2, Last: 1, AllDay: true, Start: at(2, 0), Key: "birthday"},
+				{First: 2, Last: 2, Start: at(2, 9), Key: "meeting"},
+			},
+			weeks: 1,
+			want:  [][]int{{0}, {1}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Assign(tt.spans, tt.weeks)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Assign() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// checkLayout verifies the invariants of a layout: spans sharing a day in a
+// row never share a slot, visible spans have a slot and slots are dense.
+func checkLayout(t *testing.T, spans []Span, weeks int, got [][]int) {
+	t.Helper()
+	for w := 0; w < weeks; w++ {
+		occupied := map[[2]int]int{}
+		for i, s := range spans {
+			first := max(s.First, w*DaysPerWeek)
+			last := min(max(s.Last, s.First), w*DaysPerWeek+DaysPerWeek-1)
+			visible := first <= last
+			if visible != (got[i][w] >= 0) {
+				t.Fatalf("span %d %+v in week %d has slot %d", i, s, w, got[i][w])
+			}
+			if !visible {
+				continue
+			}
+			if got[i][w] > len(spans) {
+				t.Fatalf("span %d has slot %d with only %d spans", i, got[i][w], len(spans))
+			}
+			for d := first; d <= last; d++ {
+				key := [2]int{d, got[i][w]}
+				if other, ok := occupied[key]; ok {
+					t.Fatalf("spans %d and %d share slot %d on day %d", other, i, got[i][w], d)
+				}
+				occupied[key] = i
+			}
+		}
+		// A span in slot n > 0 must have been pushed down by something in
+		// every lower slot on at least one of its days.
+		for i, s := range spans {
+			slot := got[i][w]
+			for lower := 0; lower < slot; lower++ {
+				blocked := false
+				for d := max(s.First, w*DaysPerWeek); d <= min(max(s.Last, s.First), w*DaysPerWeek+DaysPerWeek-1); d++ {
+					if _, ok := occupied[[2]int{d, lower}]; ok {
+						blocked = true
+					}
+				}
+				if !blocked {
+					t.Fatalf("span %d in slot %d of week %d but slot %d is free", i, slot, w, lower)
+				}
+			}
+		}
+	}
+}
+
+func FuzzAssign(f *testing.F) {
+	f.Add([]byte{0, 0, 1, 2, 3, 1, 5, 9, 0, 2, 2, 4})
+	f.Add([]byte{250, 40, 7, 7, 7, 7, 13, 1, 1, 1})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		const weeks = 4
+		var spans []Span
+		for i := 0; i+2 < len(data) && len(spans) < 64; i += 3 {
+			first := int(data[i]%40) - 5
+			spans = append(spans, Span{
+				First: first,
+				// Some spans end the day before they start.
+				Last:   first + int(data[i+1]%12) - 1,
+				AllDay: data[i+2]&1 == 1,
+				Start:  at(first, int(data[i+2]%24)),
+				Key:    fmt.Sprint(len(spans)),
+			})
+		}
+
+		got := Assign(spans, weeks)
+		checkLayout(t, spans, weeks, got)
+
+		// The layout does not depend on the order of the input.
+		reversed := make([]Span, len(spans))
+		for i, s := range spans {
+			reversed[len(spans)-1-i] = s
+		}
+		gotReversed := Assign(reversed, weeks)
+		for i := range spans {
+			if !reflect.DeepEqual(got[i], gotReversed[len(spans)-1-i]) {
+				t.Fatalf("span %d has slots %v, but %v when the input is reversed", i, got[i], gotReversed[len(spans)-1-i])
+			}
+		}
+	})
+}
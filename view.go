@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"time"
+	"wallcalendar/locale"
+)
+
+// View selects which days the calendar shows and how they are laid out.
+type View string
+
+const (
+	// FourWeekView shows four week rows starting with the current week.
+	FourWeekView View = "four_weeks"
+	// WeekView shows the current week in one row of large cells.
+	WeekView View = "week"
+	// TwoWeekView shows the current and the next week.
+	TwoWeekView View = "two_weeks"
+	// MonthView shows the current month in six week rows starting with the
+	// week of the 1st, greying the days of the months around it.
+	MonthView View = "month"
+	// AgendaView lists the events of the next four weeks day by day,
+	// starting today.
+	AgendaView View = "agenda"
+)
+
+// ParseView returns the view named s, FourWeekView if s is empty.
+func ParseView(s string) (View, error) {
+	switch v := View(s); v {
+	case "":
+		return FourWeekView, nil
+	case FourWeekView, WeekView, TwoWeekView, MonthView, AgendaView:
+		return v, nil
+	}
+	return "", fmt.Errorf("unknown view %q", s)
+}
+
+// Window returns the first day the view shows around today, for weeks
+// starting on weekStart, and its number of week rows.
+func (v View) Window(today time.Time, weekStart time.Weekday, tz *time.Location) (time.Time, int) {
+	switch v {
+	case WeekView:
+		return startOfWeek(today, weekStart, tz), 1
+	case TwoWeekView:
+		return startOfWeek(today, weekStart, tz), 2
+	case MonthView:
+		first := time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, tz)
+		return startOfWeek(first, weekStart, tz), 6
+	case AgendaView:
+		return midnight(today, tz), 4
+	}
+	return startOfWeek(today, weekStart, tz), 4
+}
+
+// Title returns the heading of the view in loc, the month of today for
+// MonthView and otherwise the months from start to lastDay, e.g.
+// "November/December 2024".
+func (v View) Title(loc locale.Locale, today time.Time, start time.Time, lastDay time.Time) string {
+	if v == MonthView || start.Month() == lastDay.Month() && start.Year() == lastDay.Year() {
+		return loc.MonthTitle(today, today)
+	}
+	return loc.MonthTitle(start, lastDay)
+}
+
+// Faded reports whether date is drawn greyed out, which MonthView does for
+// days outside the month of today.
+func (v View) Faded(today time.Time, date time.Time) bool {
+	return v == MonthView && (date.Month() != today.Month() || date.Year() != today.Year())
+}
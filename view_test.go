@@ -0,0 +1,63 @@
+package main
+
+import (
+	"testing"
+	"time"
+	"wallcalendar/locale"
+)
+
+func TestViewWindow(t *testing.T) {
+	ny, _ := time.LoadLocation("America/New_York")
+	today := time.Date(2024, 11, 21, 0, 0, 0, 0, ny)
+	tests := []struct {
+		view      View
+		wantStart time.Time
+		wantWeeks int
+		wantTitle string
+	}{
+		{FourWeekView, time.Date(2024, 11, 17, 0, 0, 0, 0, ny), 4, "November/December 2024"},
+		{WeekView, time.Date(2024, 11, 17, 0, 0, 0, 0, ny), 1, "November 2024"},
+		{TwoWeekView, time.Date(2024, 11, 17, 0, 0, 0, 0, ny), 2, "November 2024"},
+		{MonthView, time.Date(2024, 10, 27, 0, 0, 0, 0, ny), 6, "November 2024"},
+		{AgendaView, today, 4, "November/December 2024"},
+	}
+	for _, tt := range tests {
+		start, weeks := tt.view.Window(today, time.Sunday, ny)
+		if !start.Equal(tt.wantStart) || weeks != tt.wantWeeks {
+			t.Errorf("%s: Window() = %v, %d, want %v, %d", tt.view, start, weeks, tt.wantStart, tt.wantWeeks)
+		}
+		if got := tt.view.Title(locale.English, today, start, start.AddDate(0, 0, weeks*7-1)); got != tt.wantTitle {
+			t.Errorf("%s: Title() = %q, want %q", tt.view, got, tt.wantTitle)
+		}
+	}
+
+	if start, _ := MonthView.Window(today, time.Monday, ny); !start.Equal(time.Date(2024, 10, 28, 0, 0, 0, 0, ny)) {
+		t.Errorf("MonthView.Window(Monday) = %v, want 2024-10-28", start)
+	}
+
+	if !MonthView.Faded(today, time.Date(2024, 10, 31, 0, 0, 0, 0, ny)) || MonthView.Faded(today, time.Date(2024, 11, 1, 0, 0, 0, 0, ny)) {
+		t.Errorf("MonthView.Faded() greys the wrong days")
+	}
+	if FourWeekView.Faded(today, time.Date(2024, 12, 1, 0, 0, 0, 0, ny)) {
+		t.Errorf("FourWeekView.Faded() = true")
+	}
+}
+
+func TestFetchEventsMonthView(t *testing.T) {
+	ny, _ := time.LoadLocation("America/New_York")
+	today := time.Date(2024, 11, 21, 0, 0, 0, 0, ny)
+	window, err := FetchEvents(today, MonthView, time.Sunday, ICalSource{Location: "testdata/school.ics"}, EventCache{}, nil, ny)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if window.Weeks != 6 || !window.LastDay.Equal(time.Date(2024, 12, 7, 0, 0, 0, 0, ny)) {
+		t.Errorf("window = %d weeks to %v, want 6 weeks to 2024-12-07", window.Weeks, window.LastDay)
+	}
+	for _, events := range window.Dates {
+		for _, e := range events {
+			if len(e.weekSlots) != 6 {
+				t.Errorf("%s has slots for %d weeks, want 6", e.Summary, len(e.weekSlots))
+			}
+		}
+	}
+}